@@ -11,6 +11,7 @@ export default defineConfig({
         '/backend': {
           target: 'http://localhost:8080',
           changeOrigin: true,
+          ws: true,
           rewrite: (path) => path.replace(/^\/backend/, '')
         }
       }
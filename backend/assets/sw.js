@@ -0,0 +1,52 @@
+// Service worker for the installed dashboard. It keeps the last good copy
+// of the page, its scripts and styles, and the dashboard partial, so the
+// app still opens offline. Everything is network-first: online use always
+// sees fresh data.
+const CACHE = 'personal-dash-shell-v1';
+const DASHBOARD_PATH = new URL('partials/dashboard', self.location).pathname;
+
+self.addEventListener('install', (event) => {
+  event.waitUntil(
+    caches
+      .open(CACHE)
+      .then((cache) => cache.add(self.registration.scope))
+      .then(() => self.skipWaiting())
+  );
+});
+
+self.addEventListener('activate', (event) => {
+  event.waitUntil(
+    caches
+      .keys()
+      .then((keys) => Promise.all(keys.filter((key) => key !== CACHE).map((key) => caches.delete(key))))
+      .then(() => self.clients.claim())
+  );
+});
+
+const cacheable = (request) => {
+  if (request.method !== 'GET') return false;
+  if (request.mode === 'navigate') return true;
+  if (['script', 'style', 'manifest', 'font'].includes(request.destination)) return true;
+  return new URL(request.url).pathname === DASHBOARD_PATH;
+};
+
+self.addEventListener('fetch', (event) => {
+  const { request } = event;
+  if (!cacheable(request)) return;
+  event.respondWith(
+    fetch(request)
+      .then((response) => {
+        if (response.ok || response.type === 'opaque') {
+          const copy = response.clone();
+          caches.open(CACHE).then((cache) => cache.put(request, copy));
+        }
+        return response;
+      })
+      .catch(() =>
+        caches
+          .match(request)
+          .then((cached) => cached || (request.mode === 'navigate' ? caches.match(self.registration.scope) : undefined))
+          .then((cached) => cached || Response.error())
+      )
+  );
+});
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func lastAudit(t *testing.T, s *server) (action, entityType string, entityID int64, details string) {
+	t.Helper()
+	err := s.db.QueryRow(`SELECT action, entity_type, entity_id, details_json FROM audit_log ORDER BY id DESC LIMIT 1`).
+		Scan(&action, &entityType, &entityID, &details)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	return action, entityType, entityID, details
+}
+
+func TestRemoveFavoriteIsAudited(t *testing.T) {
+	s := newTestServer(t)
+	res := mustExec(t, s, `INSERT INTO links(category_id, name, url, position) VALUES(1, 'Fav', 'https://example.com', 0)`)
+	id, _ := res.LastInsertId()
+	mustExec(t, s, `INSERT INTO favorites(link_id, position) VALUES(?, 0)`, id)
+
+	s.handleRemoveFavorite(httptest.NewRecorder(), httptest.NewRequest("POST", "/actions/links/1/unfavorite", nil), id)
+
+	action, entityType, entityID, _ := lastAudit(t, s)
+	if action != "unfavorite" || entityType != "link" || entityID != id {
+		t.Errorf("audit entry = %s %s %d, want unfavorite link %d", action, entityType, entityID, id)
+	}
+}
+
+func TestUnshareCategoryIsAuditedWithoutToken(t *testing.T) {
+	s := newTestServer(t)
+	mustExec(t, s, `INSERT INTO category_shares(token, category_id, created_at) VALUES('secret-token', 1, 0)`)
+
+	req := httptest.NewRequest("POST", "/actions/categories/1/unshare", strings.NewReader(`token=secret-token`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.handleUnshareCategory(httptest.NewRecorder(), req, 1)
+
+	action, entityType, entityID, details := lastAudit(t, s)
+	if action != "unshare" || entityType != "category" || entityID != 1 {
+		t.Errorf("audit entry = %s %s %d, want unshare category 1", action, entityType, entityID)
+	}
+	if strings.Contains(details, "secret-token") {
+		t.Errorf("audit details %s hold the share token", details)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func createCategory(s *server, name string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/actions/categories/create", strings.NewReader(`{"name":"`+name+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handleCreateCategory(rec, req)
+	return rec
+}
+
+func TestCreateCategoryLimitBoundary(t *testing.T) {
+	s := newTestServer(t)
+	var seeded int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM categories`).Scan(&seeded); err != nil {
+		t.Fatal(err)
+	}
+	s.maxCategories = seeded + 1
+
+	if rec := createCategory(s, "Last one"); rec.Code != http.StatusCreated {
+		t.Fatalf("creating up to the limit: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	rec := createCategory(s, "One too many")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("creating past the limit: status %d, want 409", rec.Code)
+	}
+	if want := "(" + strconv.Itoa(seeded+1) + " of " + strconv.Itoa(seeded+1) + " categories)"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("body %q does not report the count %q", rec.Body.String(), want)
+	}
+
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM categories`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != seeded+1 {
+		t.Errorf("categories = %d after a refused create, want %d", count, seeded+1)
+	}
+}
+
+func TestCreateCategoryUnlimited(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"} {
+		if rec := createCategory(s, name); rec.Code != http.StatusCreated {
+			t.Fatalf("create %s with no limit: status %d", name, rec.Code)
+		}
+	}
+}
+
+func TestLoadConfigMaxCategories(t *testing.T) {
+	t.Setenv("MAX_CATEGORIES", "12")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.maxCategories != 12 {
+		t.Errorf("maxCategories = %d, want 12", cfg.maxCategories)
+	}
+	for _, bad := range []string{"-1", "lots"} {
+		t.Setenv("MAX_CATEGORIES", bad)
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("MAX_CATEGORIES=%q was accepted", bad)
+		}
+	}
+}
+
+func TestDuplicateCategoryKeepsLinkState(t *testing.T) {
+	s := newTestServer(t)
+	mustExec(t, s, `INSERT INTO links(name, url, category_id, position, created_at, updated_at, enabled, expires_at)
+	                VALUES('Off', 'https://example.com', 1, 0, 0, 0, 0, 4102444800)`)
+
+	req := httptest.NewRequest("POST", "/actions/categories/1/duplicate", strings.NewReader(`{"name":"Copy"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handleDuplicateCategory(rec, req, 1)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("duplicate: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var enabled bool
+	var expiresAt int64
+	err := s.db.QueryRow(`SELECT l.enabled, l.expires_at FROM links l JOIN categories c ON c.id = l.category_id WHERE c.name = 'Copy'`).
+		Scan(&enabled, &expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Error("the copy of a disabled link is enabled")
+	}
+	if expiresAt != 4102444800 {
+		t.Errorf("copy expires_at = %d, want 4102444800", expiresAt)
+	}
+}
+
+// TestImplicitCategoryLimit checks that categories created on the side,
+// here the Uncategorized fallback of a link without one, count against
+// MAX_CATEGORIES too.
+func TestImplicitCategoryLimit(t *testing.T) {
+	s := newTestServer(t)
+	var seeded int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM categories`).Scan(&seeded); err != nil {
+		t.Fatal(err)
+	}
+	s.maxCategories = seeded
+
+	rec := createLink(s, `{"name":"Docs","url":"https://example.com"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("link needing a new category at the limit: status %d, want 409", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "category limit reached") {
+		t.Errorf("body %q does not name the category limit", rec.Body.String())
+	}
+	if n := uncategorizedCount(t, s); n != 0 {
+		t.Errorf("%s categories = %d past the limit, want 0", uncategorizedName, n)
+	}
+
+	if rec := createLink(s, `{"name":"Docs","url":"https://example.com","category_id":1}`); rec.Code != http.StatusCreated {
+		t.Errorf("link into an existing category at the limit: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func sortedNames(locale string, names ...string) []string {
+	sorted := slices.Clone(names)
+	slices.SortStableFunc(sorted, newNameComparer(locale))
+	return sorted
+}
+
+func TestNameComparerLocales(t *testing.T) {
+	tests := []struct {
+		locale string
+		names  []string
+		want   []string
+	}{
+		// ASCII folding puts every accented letter after z.
+		{"", []string{"Zebra", "Éclair", "eagle", "Apfel"}, []string{"Apfel", "eagle", "Zebra", "Éclair"}},
+		{"en", []string{"Zebra", "Éclair", "eagle", "Apfel"}, []string{"Apfel", "eagle", "Éclair", "Zebra"}},
+		{"en", []string{"Ärger", "Apfel", "Zucker", "arm"}, []string{"Apfel", "Ärger", "arm", "Zucker"}},
+		// Swedish sorts Ö as its own letter after z; German does not.
+		{"sv", []string{"Öl", "Zebra", "Apa"}, []string{"Apa", "Zebra", "Öl"}},
+		{"de", []string{"Öl", "Zebra", "Apa"}, []string{"Apa", "Öl", "Zebra"}},
+		{"ru", []string{"Яблоко", "банан", "Арбуз", "ёж", "Ель"}, []string{"Арбуз", "банан", "ёж", "Ель", "Яблоко"}},
+		{"el", []string{"Ωμέγα", "άλφα", "Βήτα"}, []string{"άλφα", "Βήτα", "Ωμέγα"}},
+	}
+	for _, tt := range tests {
+		if got := sortedNames(tt.locale, tt.names...); !slices.Equal(got, tt.want) {
+			t.Errorf("locale %q sorted %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigInvalidLocaleFallsBack(t *testing.T) {
+	captureLog(t)
+	t.Setenv("LOCALE", "not a tag!")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.locale != "" {
+		t.Errorf("locale = %q, want ASCII folding for an invalid LOCALE", cfg.locale)
+	}
+}
+
+// TestRankSearchResultsTiebreakUsesLocale gives every result the same
+// score, so only the name comparison decides the order.
+func TestRankSearchResultsTiebreakUsesLocale(t *testing.T) {
+	var results []searchResult
+	for i, name := range []string{"Zebra", "Éclair", "eagle", "Ärger", "Apfel"} {
+		results = append(results, searchResult{ID: int64(i + 1), Link: quickOpenItem{Name: name}, Score: 100})
+	}
+	rankSearchResults(results, newNameComparer("en"))
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Link.Name)
+	}
+	if want := []string{"Apfel", "Ärger", "eagle", "Éclair", "Zebra"}; !slices.Equal(got, want) {
+		t.Errorf("tied results ranked %q, want %q", got, want)
+	}
+}
+
+func TestDashboardNameSortUsesLocale(t *testing.T) {
+	s := newTestServer(t)
+	for i, name := range []string{"Zebra", "Éclair", "eagle", "Ärger"} {
+		mustExec(t, s, `INSERT INTO links(category_id, name, url, position) VALUES(1, ?, 'https://example.com', ?)`, name, i)
+	}
+	data, err := s.getDashboardData(context.Background(), 0, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range data.Categories {
+		if c.ID == "1" {
+			for _, link := range c.Links {
+				got = append(got, link.Name)
+			}
+		}
+	}
+	if want := []string{"Ärger", "eagle", "Éclair", "Zebra"}; !slices.Equal(got, want) {
+		t.Errorf("name sort = %q, want %q", got, want)
+	}
+}
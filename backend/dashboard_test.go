@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// seedLinks adds n links spread over the seeded categories of panel 1.
+func seedLinks(tb testing.TB, s *server, n int) {
+	tb.Helper()
+	for i := range n {
+		mustExec(tb, s,
+			`INSERT INTO links(category_id, name, url, position) VALUES(?, ?, ?, ?)`,
+			i%4+1, fmt.Sprintf("Link %03d", i), fmt.Sprintf("https://example.com/%d", i), i,
+		)
+	}
+}
+
+func countLinks(data dashboardData) int {
+	n := 0
+	for _, c := range data.Categories {
+		n += len(c.Links)
+	}
+	return n
+}
+
+func TestGetDashboardDataCache(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	seedLinks(t, s, 3)
+
+	first, err := s.getDashboardData(ctx, 0, defaultLinkSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countLinks(first); got != 3 {
+		t.Fatalf("links = %d, want 3", got)
+	}
+
+	// Without markChanged the cached copy is served, so a write made
+	// behind the server's back is not seen yet.
+	seedLinks(t, s, 1)
+	cached, err := s.getDashboardData(ctx, 0, defaultLinkSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countLinks(cached); got != 3 {
+		t.Fatalf("cached links = %d, want 3", got)
+	}
+
+	s.markChanged(changeEvent{Type: "link.created"})
+	fresh, err := s.getDashboardData(ctx, 0, defaultLinkSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countLinks(fresh); got != 4 {
+		t.Fatalf("links after markChanged = %d, want 4", got)
+	}
+}
+
+// BenchmarkGetDashboardData compares serving the dashboard from the cache
+// with rebuilding it from SQLite on every call.
+func BenchmarkGetDashboardData(b *testing.B) {
+	s := newTestServer(b)
+	ctx := context.Background()
+	seedLinks(b, s, 200)
+
+	b.Run("cached", func(b *testing.B) {
+		if _, err := s.getDashboardData(ctx, 0, defaultLinkSort); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for range b.N {
+			if _, err := s.getDashboardData(ctx, 0, defaultLinkSort); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for range b.N {
+			s.version.Add(1)
+			if _, err := s.getDashboardData(ctx, 0, defaultLinkSort); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRefuseBlockedAddr(t *testing.T) {
+	tests := []struct {
+		address string
+		blocked bool
+	}{
+		{"127.0.0.1:80", true},
+		{"[::1]:443", true},
+		{"10.1.2.3:80", true},
+		{"172.16.0.1:80", true},
+		{"172.31.255.255:80", true},
+		{"192.168.1.10:8080", true},
+		{"169.254.169.254:80", true},
+		{"[fe80::1]:80", true},
+		{"[fc00::1]:80", true},
+		{"100.64.0.1:80", true},
+		{"100.127.255.254:80", true},
+		{"0.0.0.0:80", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		{"93.184.216.34:443", false},
+		{"172.32.0.1:80", false},
+		{"100.128.0.1:80", false},
+		{"[2606:4700::1111]:443", false},
+	}
+	for _, tt := range tests {
+		err := refuseBlockedAddr("tcp", tt.address, nil)
+		if got := errors.Is(err, errBlockedAddress); got != tt.blocked {
+			t.Errorf("refuseBlockedAddr(%q) = %v, want blocked %v", tt.address, err, tt.blocked)
+		}
+	}
+}
+
+// TestFetchClientGuard resolves a host name to a loopback test server, so
+// the guard has to act on the resolved address rather than the URL.
+func TestFetchClientGuard(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+	byName := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	for _, url := range []string{target.URL, byName} {
+		_, err := newFetchClient(false).Get(url)
+		if !errors.Is(err, errBlockedAddress) {
+			t.Errorf("guarded GET %s: err = %v, want errBlockedAddress", url, err)
+		}
+
+		resp, err := newFetchClient(true).Get(url)
+		if err != nil {
+			t.Errorf("GET %s with private fetches allowed: %v", url, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("GET %s with private fetches allowed: status %d", url, resp.StatusCode)
+		}
+	}
+}
+
+// TestFetchClientGuardRedirect checks that a public-looking first hop
+// cannot bounce the request into a private address.
+func TestFetchClientGuardRedirect(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer internal.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer redirect.Close()
+
+	client := newFetchClient(true)
+	client.Transport.(*http.Transport).DialContext = guardedDialer(redirect.Listener.Addr().String())
+	_, err := client.Get(redirect.URL)
+	if !errors.Is(err, errBlockedAddress) {
+		t.Fatalf("redirect into a private address: err = %v, want errBlockedAddress", err)
+	}
+}
+
+// guardedDialer dials like the guarded fetch client, except that allowed
+// stands in for a public server.
+func guardedDialer(allowed string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Control: func(network, address string, c syscall.RawConn) error {
+		if address == allowed {
+			return nil
+		}
+		return refuseBlockedAddr(network, address, c)
+	}}
+	return dialer.DialContext
+}
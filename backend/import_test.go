@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDocumentImportCategoryCapacity(t *testing.T) {
+	s := newTestServer(t)
+	s.maxLinksPerCategory = 2
+	ctx := context.Background()
+	links := []exportLink{{Name: "A", URL: "https://a.example"}, {Name: "B", URL: "https://b.example"}}
+	doc := exportDocument{Version: 1, Panels: []exportPanel{{
+		Name: "Imported",
+		Categories: []exportCategory{
+			{Name: "Reading", Links: links},
+			{Name: "Reading", Links: links[:1]},
+		},
+	}}}
+
+	plan := importPlan{PanelsToCreate: []string{}, CategoriesToCreate: []string{}, Failures: []importFailure{}}
+	if err := s.planDocumentImport(ctx, &plan, doc); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Accepted {
+		t.Error("plan accepts three links into a category capped at two")
+	}
+	if len(plan.Failures) != 1 || !strings.Contains(plan.Failures[0].Reason, "Imported/Reading: category is full") {
+		t.Errorf("failures = %+v, want one full Imported/Reading", plan.Failures)
+	}
+
+	_, err := s.importDocument(ctx, doc)
+	var full categoryFullError
+	if !errors.As(err, &full) {
+		t.Fatalf("import err = %v, want categoryFullError", err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM panels WHERE name = 'Imported'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("a refused import left its panel behind")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWithLimitsOutlastsReadTimeout uploads a body more slowly than the
+// server's ReadTimeout allows; the route's own timeout must win.
+func TestWithLimitsOutlastsReadTimeout(t *testing.T) {
+	handler := withLimits(routeLimits{timeout: 5 * time.Second, maxBytes: 1 << 20}, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, strconv.Itoa(len(body)))
+	})
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config.ReadTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	body, upload := io.Pipe()
+	go func() {
+		for range 6 {
+			time.Sleep(100 * time.Millisecond)
+			upload.Write([]byte("0123456789"))
+		}
+		upload.Close()
+	}()
+	resp, err := http.Post(ts.URL, "text/csv", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(got) != "60" {
+		t.Fatalf("slow upload: status %d, body %q; want all 60 bytes read", resp.StatusCode, got)
+	}
+}
+
+func TestWithLimitsCapsBody(t *testing.T) {
+	handler := withLimits(routeLimits{timeout: time.Second, maxBytes: 10}, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/actions/import/csv", strings.NewReader("more than ten bytes")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", rec.Code)
+	}
+}
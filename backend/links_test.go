@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createLink(s *server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/actions/links/create", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.handleCreateLink(rec, req)
+	return rec
+}
+
+func uncategorizedCount(t *testing.T, s *server) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM categories WHERE name = ?`, uncategorizedName).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCreateLinkWithoutCategory(t *testing.T) {
+	s := newTestServer(t)
+	version := s.version.Load()
+
+	rec := createLink(s, `{"name":"","url":"not a url"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid link: status %d, want 400", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "category_id") {
+		t.Errorf("invalid link without a category reports category_id: %s", rec.Body.String())
+	}
+	if n := uncategorizedCount(t, s); n != 0 {
+		t.Errorf("a rejected link left %d %s categories", n, uncategorizedName)
+	}
+	if s.version.Load() != version {
+		t.Error("a rejected link moved the data version")
+	}
+
+	if rec := createLink(s, `{"name":"Docs","url":"https://example.com"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("valid link: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if n := uncategorizedCount(t, s); n != 1 {
+		t.Fatalf("%s categories = %d, want 1", uncategorizedName, n)
+	}
+	if got := s.version.Load() - version; got != 2 {
+		t.Errorf("version moved by %d, want 2 for the category and the link", got)
+	}
+
+	version = s.version.Load()
+	if rec := createLink(s, `{"name":"More","url":"https://example.org"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("second link: status %d", rec.Code)
+	}
+	if n := uncategorizedCount(t, s); n != 1 {
+		t.Errorf("%s categories = %d after reuse, want 1", uncategorizedName, n)
+	}
+	if got := s.version.Load() - version; got != 1 {
+		t.Errorf("version moved by %d, want 1 when the category already exists", got)
+	}
+}
+
+// createdID reads the id from a JSON create response.
+func createdID(t *testing.T, rec *httptest.ResponseRecorder) int64 {
+	t.Helper()
+	var created struct {
+		ID int64 `json:"id,string"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+	return created.ID
+}
+
+func TestDuplicateLinkKeepsState(t *testing.T) {
+	s := newTestServer(t)
+	rec := createLink(s, `{"name":"Docs","url":"https://example.com","category_id":1}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	id := createdID(t, rec)
+	expires := time.Now().Add(time.Hour).Unix()
+	mustExec(t, s, `UPDATE links SET enabled = 0, expires_at = ? WHERE id = ?`, expires, id)
+
+	rec = httptest.NewRecorder()
+	s.handleDuplicateLink(rec, httptest.NewRequest("POST", "/actions/links/1/duplicate", nil), id)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("duplicate: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var enabled bool
+	var expiresAt int64
+	err := s.db.QueryRow(`SELECT enabled, expires_at FROM links WHERE id <> ? AND name = 'Docs'`, id).Scan(&enabled, &expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Error("the copy of a disabled link is enabled")
+	}
+	if expiresAt != expires {
+		t.Errorf("copy expires_at = %d, want %d", expiresAt, expires)
+	}
+}
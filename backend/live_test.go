@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func (h *changeHub) subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestChangeHubSlowSubscriberNeverBlocks(t *testing.T) {
+	var hub changeHub
+	slow, ok := hub.subscribe()
+	if !ok {
+		t.Fatal("subscribe on a new hub failed")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for version := int64(1); version <= 100; version++ {
+			hub.notify(version)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify blocked on a subscriber that never reads")
+	}
+	if got := <-slow; got != 100 {
+		t.Errorf("slow subscriber got version %d, want only the newest, 100", got)
+	}
+	select {
+	case got := <-slow:
+		t.Errorf("slow subscriber got a second message %d", got)
+	default:
+	}
+}
+
+func TestChangeHubClose(t *testing.T) {
+	var hub changeHub
+	ch, _ := hub.subscribe()
+	hub.close()
+	if _, open := <-ch; open {
+		t.Error("subscription still open after close")
+	}
+	if _, ok := hub.subscribe(); ok {
+		t.Error("subscribe succeeded on a closed hub")
+	}
+	hub.notify(1)
+}
+
+func TestLiveUpdatesSendReload(t *testing.T) {
+	s := &server{}
+	ts := httptest.NewServer(websocket.Server{Handler: s.serveLiveUpdates})
+	defer ts.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the connection to subscribe", func() bool { return s.live.subscribers() == 1 })
+
+	s.markChanged(changeEvent{Type: "link.created", ID: 1})
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	var msg liveMessage
+	if err := websocket.JSON.Receive(conn, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (liveMessage{Type: "reload", Version: s.version.Load()}); msg != want {
+		t.Errorf("message = %+v, want %+v", msg, want)
+	}
+
+	conn.Close()
+	waitFor(t, "the closed connection to unsubscribe", func() bool { return s.live.subscribers() == 0 })
+}
+
+func TestLiveUpdatesEndOnHubClose(t *testing.T) {
+	s := &server{}
+	ts := httptest.NewServer(websocket.Server{Handler: s.serveLiveUpdates})
+	defer ts.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, "the connection to subscribe", func() bool { return s.live.subscribers() == 1 })
+
+	s.live.close()
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	var msg liveMessage
+	if err := websocket.JSON.Receive(conn, &msg); !errors.Is(err, io.EOF) {
+		t.Errorf("got %+v, %v after the hub closed, want the connection closed", msg, err)
+	}
+}
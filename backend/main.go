@@ -1,69 +1,384 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"embed"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"html/template"
+	"io"
+	"io/fs"
 	"log"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
-	_ "modernc.org/sqlite"
+	"github.com/gomarkdown/markdown"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/websocket"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 const requestTimeout = 8 * time.Second
 
+// Input limits for user-supplied text. Lengths are counted in characters,
+// maxFormBytes caps the raw body of any create/update request.
+const (
+	maxNameLength        = 200
+	maxURLLength         = 2048
+	maxDescriptionLength = 4000
+	maxNotesLength       = 20000
+	// maxCategoryDescriptionLength keeps category subtitles to a line.
+	maxCategoryDescriptionLength = 280
+	maxFormBytes                 = 64 << 10
+	// maxHotkeyKeys bounds a link hotkey sequence such as "g h".
+	maxHotkeyKeys = 3
+)
+
+// backupTimeout bounds a single VACUUM INTO, which has to copy the whole
+// database and can take much longer than a normal request.
+const backupTimeout = 2 * time.Minute
+
+// Defaults for the /actions/import/ routes, which handle whole files and
+// may fetch page titles, so they get more room than other actions.
+const (
+	defaultImportTimeout  = 2 * time.Minute
+	defaultImportMaxBytes = 8 << 20
+)
+
+// Default HTTP server timeouts. Reading headers gets little time so slow
+// clients cannot hold connections open; the write timeout covers the
+// slowest handlers, imports and backups.
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = time.Minute
+	defaultWriteTimeout      = 5 * time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
+)
+
+// defaultDeleteConfirmThreshold is how many categories and links one
+// delete may remove before it needs confirm=true.
+const defaultDeleteConfirmThreshold = 10
+
+// maxIconBytes caps an uploaded link icon; icons are shown at favicon
+// size so anything larger is almost certainly the wrong file.
+const maxIconBytes = 256 << 10
+
+// maintenanceTimeout bounds the optimize endpoint; VACUUM rewrites the
+// whole file.
+const maintenanceTimeout = 5 * time.Minute
+
+// integrityTimeout bounds the integrity endpoint; both checks scan the
+// entire database.
+const integrityTimeout = 2 * time.Minute
+
 var defaultPanels = []string{"Work", "Personal"}
 var defaultCategories = []string{"Learning", "Entertainment", "Favorites", "Quick Links"}
 
+// markdownPolicy strips anything from rendered descriptions that could run
+// script or break out of the card markup.
+var markdownPolicy = bluemonday.UGCPolicy().
+	RequireNoReferrerOnLinks(true).
+	AddTargetBlankToFullyQualifiedLinks(true)
+
 type server struct {
 	db        *sql.DB
 	templates *template.Template
+	// version is bumped after every successful mutation and backs the
+	// dashboard ETag and /api/state. It is seeded from the clock so tags
+	// never repeat across restarts, in milliseconds so it stays exact as
+	// a JavaScript number.
+	version atomic.Int64
+	undo    undoLog
+	cache   dashboardCache
+	state   stateHashCache
+	// live pushes version changes to /ws clients.
+	live changeHub
+	// maxLinksPerCategory caps how many links one category may hold;
+	// zero means unlimited.
+	maxLinksPerCategory int
+	// maxCategories caps how many categories may exist across all panels;
+	// zero means unlimited.
+	maxCategories int
+	// collapseThreshold collapses categories with more visible links than
+	// this unless the user expanded them; zero disables it.
+	collapseThreshold int
+	// deleteConfirmThreshold is how many categories and links a single
+	// delete may remove before it needs confirm=true; zero never asks.
+	deleteConfirmThreshold int
+	// categoryOrder is the ORDER BY clause for categories, picked from
+	// categorySortOrders by CATEGORY_SORT.
+	categoryOrder string
+	// insertionOrder shows links oldest first instead of in their
+	// drag-and-drop positions (ORDER_BY_INSERTION).
+	insertionOrder bool
+	// autoUncategorized keeps an Uncategorized category on the first
+	// panel as the fallback for links whose category is missing
+	// (AUTO_UNCATEGORIZED).
+	autoUncategorized bool
+	// location is the time zone link visibility windows are read in.
+	location *time.Location
+	// importLimits replace requestTimeout and maxFormBytes on the
+	// /actions/import/ routes.
+	importLimits routeLimits
+	// cipher encrypts link URLs and descriptions at rest when
+	// DB_PASSPHRASE is set; nil stores them as plain text.
+	cipher *fieldCipher
+	// webhook receives a changeEvent after every mutation when
+	// WEBHOOK_URL is set.
+	webhook *webhook
+	// iconProvider is the ICON_PROVIDER template; empty means
+	// defaultIconProvider.
+	iconProvider string
+	// basePath is BASE_PATH, where the dashboard page is served; the web
+	// app manifest starts there and the service worker covers it.
+	basePath string
+	// pin locks the /actions/ routes behind PIN; nil leaves them open.
+	pin *pinLock
+	// compareNames is the LOCALE comparison behind nameCollation, for
+	// names sorted in Go; nil folds ASCII letters only.
+	compareNames func(a, b string) int
+}
+
+// routeLimits bound one request's body size and total running time.
+type routeLimits struct {
+	timeout  time.Duration
+	maxBytes int64
+}
+
+// withLimits applies limits to a route whose handler does not set its own
+// body cap or deadline, so the route can allow more than the defaults
+// without loosening them anywhere else. The connection's read deadline
+// moves to the same timeout, since HTTP_READ_TIMEOUT would otherwise cut
+// a large upload off first.
+func withLimits(limits routeLimits, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(limits.timeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("extend read deadline for %s: %v", r.URL.Path, err)
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limits.maxBytes)
+		ctx, cancel := context.WithTimeout(r.Context(), limits.timeout)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
 }
 
 type dashboardPanel struct {
-	ID   string
-	Name string
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type dashboardCategory struct {
-	ID    string
-	Name  string
-	Links []dashboardLink
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Collapsed is the rendered state: collapseState, or for categories
+	// left on collapseAuto, whether they exceed COLLAPSE_THRESHOLD.
+	Collapsed     bool `json:"collapsed"`
+	collapseState int
+	// Home is the HOME_CATEGORY setting's category, always shown first.
+	Home bool `json:"home"`
+	// Default is the DEFAULT_CATEGORY setting's category.
+	Default bool `json:"default"`
+	// Archived categories are left off the dashboard, links included,
+	// until they are unarchived.
+	Archived bool `json:"archived"`
+	// Shared is set while the category has a read-only /share/{token}
+	// link.
+	Shared bool            `json:"shared"`
+	Links  []dashboardLink `json:"links"`
 }
 
+// Values of categories.collapsed. Toggling only ever stores
+// collapseClosed or collapseOpen, so a category the user expanded stays
+// open however many links it gains.
+const (
+	collapseOpen   = -1
+	collapseAuto   = 0
+	collapseClosed = 1
+)
+
 type dashboardLink struct {
-	ID           string
-	CategoryID   string
-	CategoryName string
-	Name         string
-	URL          string
-	Description  string
-	LogoURL      string
+	ID           string `json:"id"`
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	// Description holds the raw markdown as entered; DescriptionHTML is
+	// the sanitized rendering shown on the card.
+	Description     string        `json:"description"`
+	DescriptionHTML template.HTML `json:"-"`
+	LogoURL         string        `json:"logo_url"`
+	// IconDataURI is an inline monogram shown when there is no logo.
+	IconDataURI template.URL `json:"-"`
+	// IconVersion is the upload time of a custom icon, 0 when there is
+	// none; it doubles as a cache buster in the icon URL.
+	IconVersion   int64     `json:"icon_version"`
+	ClickCount    int       `json:"click_count"`
+	LastOpenedAt  time.Time `json:"last_opened_at"`
+	OGTitle       string    `json:"og_title"`
+	OGDescription string    `json:"og_description"`
+	OGImage       string    `json:"og_image"`
+	TargetBlank   bool      `json:"target_blank"`
+	// Confirm makes /go/{id} ask before following the link.
+	Confirm bool `json:"confirm"`
+	// Favorite is set for links on the favorites bar.
+	Favorite bool `json:"favorite"`
+	// LastStatus is the HTTP status from the most recent check, 0 when the
+	// site could not be reached; LastCheckedAt is zero if never checked.
+	LastStatus    int       `json:"last_status"`
+	LastCheckedAt time.Time `json:"last_checked_at"`
+	// VisibleFrom and VisibleTo are "HH:MM" times of day bounding when the
+	// link is shown; empty means no bound on that side.
+	VisibleFrom string `json:"visible_from"`
+	VisibleTo   string `json:"visible_to"`
+	// Hotkey is the key sequence that opens the link, e.g. "g h"; empty
+	// when none is set.
+	Hotkey string `json:"hotkey"`
+	// ExpiresAt is when the link stops showing on the dashboard; zero
+	// means it never expires.
+	ExpiresAt time.Time `json:"expires_at"`
+	// Disabled links stay in place but are dimmed and cannot be opened.
+	Disabled bool `json:"disabled"`
+}
+
+// ExpiredAt reports whether the link's expiry has passed at now.
+func (l dashboardLink) ExpiredAt(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !l.ExpiresAt.After(now)
+}
+
+// Healthy reports whether the last check got a non-error response.
+func (l dashboardLink) Healthy() bool {
+	return l.LastStatus >= 200 && l.LastStatus < 400
+}
+
+type dashboardPreset struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type dashboardStats struct {
-	TotalLinks      int
-	Favorites       int
-	RecentAdded     int
-	TotalCategories int
+	TotalLinks      int `json:"total_links"`
+	Favorites       int `json:"favorites"`
+	RecentAdded     int `json:"recent_added"`
+	TotalCategories int `json:"total_categories"`
 }
 
 type dashboardData struct {
-	Panels      []dashboardPanel
-	ActivePanel string
+	Panels      []dashboardPanel    `json:"panels"`
+	ActivePanel string              `json:"active_panel"`
+	Categories  []dashboardCategory `json:"categories"`
+	QuickLinks  []dashboardLink     `json:"quick_links"`
+	// FavoritesBar is the ordered favorites strip, shared by all panels.
+	FavoritesBar []dashboardLink `json:"favorites_bar"`
+	// ArchivedCategories hold the panel's archived categories with their
+	// links; they are not counted in Stats.
+	ArchivedCategories []dashboardCategory `json:"archived_categories"`
+	Presets            []dashboardPreset   `json:"presets"`
+	Stats              dashboardStats      `json:"stats"`
+	SearchHint         string              `json:"-"`
+	FormPanelID        string              `json:"-"`
+	Sort               string              `json:"sort"`
+	// View is the bookmark layout, "detailed" cards or a "compact" list.
+	View       string `json:"view"`
+	PanelNotes string `json:"panel_notes"`
+	// Notice is a one-off message shown above the dashboard after an
+	// action, such as the lines an import skipped.
+	Notice string `json:"notice"`
+	// SiteTitle and SiteSubtitle name the dashboard in the page title and
+	// header, from the site_title and site_subtitle settings.
+	SiteTitle    string `json:"site_title"`
+	SiteSubtitle string `json:"site_subtitle"`
+	// AccentColor and BackgroundColor are the accent_color and
+	// background_color settings, empty while unset.
+	AccentColor     string `json:"accent_color"`
+	BackgroundColor string `json:"background_color"`
+	// PinRequired shows the unlock form when PIN is set.
+	PinRequired bool `json:"pin_required"`
+}
+
+// Empty reports a panel without categories, archived ones aside. Such a
+// dashboard shows the "onboarding" block in place of its categories.
+func (d dashboardData) Empty() bool {
+	return len(d.Categories) == 0
+}
+
+// LinkForm is the empty "Add New Link" form for this dashboard.
+func (d dashboardData) LinkForm() linkFormView {
+	return linkFormView{FormPanelID: d.FormPanelID, Categories: d.Categories, Values: linkFormValues{TargetBlank: true}}
+}
+
+// CategoryView wraps one of this dashboard's categories for the
+// "category-column" template.
+func (d dashboardData) CategoryView(c dashboardCategory) categoryView {
+	return categoryView{Category: c, FormPanelID: d.FormPanelID, Categories: d.Categories}
+}
+
+// categoryView feeds the "category-column" template: the category plus
+// the panel fields its forms need.
+type categoryView struct {
+	Category    dashboardCategory
+	FormPanelID string
 	Categories  []dashboardCategory
-	QuickLinks  []dashboardLink
-	Stats       dashboardStats
-	SearchHint  string
+}
+
+// linkFormView feeds the "link-form" template, either blank or re-filled
+// with a rejected submission and its field errors.
+type linkFormView struct {
 	FormPanelID string
-	PanelNotes  string
+	Categories  []dashboardCategory
+	Values      linkFormValues
+	Errors      fieldErrors
+}
+
+type linkFormValues struct {
+	Name        string
+	URL         string
+	Description string
+	CategoryID  string
+	TargetBlank bool
+	Confirm     bool
+	Hotkey      string
+	ExpiresAt   string
 }
 
 func main() {
@@ -72,8 +387,19 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(cfg.sqlitePath), 0o755); err != nil {
-		log.Fatalf("create sqlite directory: %v", err)
+	if cfg.allowPrivateFetch {
+		fetchClient = newFetchClient(true)
+	}
+
+	if dbFile := sqliteFilePath(cfg.sqlitePath); dbFile != "" {
+		if err := os.MkdirAll(filepath.Dir(dbFile), 0o755); err != nil {
+			log.Fatalf("create sqlite directory: %v", err)
+		}
+	}
+
+	compareNames := newNameComparer(cfg.locale)
+	if err := sqlite.RegisterCollationUtf8(nameCollation, compareNames); err != nil {
+		log.Fatalf("register name collation: %v", err)
 	}
 
 	db, err := sql.Open("sqlite", cfg.sqlitePath)
@@ -95,987 +421,9502 @@ func main() {
 	if err := ensureSchema(db); err != nil {
 		log.Fatalf("ensure schema: %v", err)
 	}
-
-	tpl, err := template.ParseFiles("templates/dashboard.html")
+	fc, err := setupFieldCipher(db, cfg.dbPassphrase)
 	if err != nil {
-		log.Fatalf("parse templates: %v", err)
+		log.Fatalf("set up encryption: %v", err)
 	}
-
-	s := &server{db: db, templates: tpl}
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", s.handleHealth)
-	mux.HandleFunc("/partials/dashboard", s.handleDashboard)
-	mux.HandleFunc("/actions/panels/create", s.handleCreatePanel)
-	mux.HandleFunc("/actions/panels/", s.handlePanelActions)
-	mux.HandleFunc("/actions/categories/create", s.handleCreateCategory)
-	mux.HandleFunc("/actions/categories/", s.handleCategoryActions)
-	mux.HandleFunc("/actions/links/create", s.handleCreateLink)
-	mux.HandleFunc("/actions/links/", s.handleLinkActions)
-	mux.HandleFunc("/actions/reorder/categories", s.handleReorderCategories)
-	mux.HandleFunc("/actions/reorder/links", s.handleReorderLinks)
-
-	addr := fmt.Sprintf(":%s", cfg.port)
-	log.Printf("api listening at http://localhost%s", addr)
-	if err := http.ListenAndServe(addr, loggingMiddleware(mux)); err != nil {
-		log.Fatal(err)
+	if err := refreshDerivedLogos(db, fc, cfg.iconProvider); err != nil {
+		log.Fatalf("refresh link logos: %v", err)
 	}
-}
 
-type config struct {
-	sqlitePath string
-	port       string
-}
+	if len(os.Args) > 1 {
+		cli := &server{db: db, maxCategories: cfg.maxCategories, maxLinksPerCategory: cfg.maxLinksPerCategory, categoryOrder: cfg.categoryOrder(), insertionOrder: cfg.orderByInsertion, cipher: fc, iconProvider: cfg.iconProvider}
+		if err := runCommand(cli, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-func loadConfig() (config, error) {
-	sqlitePath := strings.TrimSpace(os.Getenv("SQLITE_PATH"))
-	port := strings.TrimSpace(os.Getenv("PORT"))
+	if cfg.autoUncategorized {
+		startup := &server{db: db, maxCategories: cfg.maxCategories}
+		if _, err := startup.ensureUncategorized(ctx); err != nil {
+			log.Fatalf("create %s category: %v", uncategorizedName, err)
+		}
+	}
 
-	if sqlitePath == "" {
-		sqlitePath = "./data/personal_dash.db"
+	tpl, err := template.New("").Funcs(templateFuncs).ParseGlob("templates/*.html")
+	if err != nil {
+		log.Fatalf("parse templates: %v", err)
 	}
-	if port == "" {
-		port = "8080"
+	if err := checkTemplates(tpl); err != nil {
+		log.Fatalf("check templates: %v", err)
 	}
 
-	return config{sqlitePath: sqlitePath, port: port}, nil
-}
+	s := &server{
+		db:                     db,
+		templates:              tpl,
+		maxLinksPerCategory:    cfg.maxLinksPerCategory,
+		maxCategories:          cfg.maxCategories,
+		collapseThreshold:      cfg.collapseThreshold,
+		deleteConfirmThreshold: cfg.deleteConfirmThreshold,
+		categoryOrder:          cfg.categoryOrder(),
+		insertionOrder:         cfg.orderByInsertion,
+		autoUncategorized:      cfg.autoUncategorized,
+		location:               cfg.location,
+		importLimits:           cfg.importLimits,
+		cipher:                 fc,
+		iconProvider:           cfg.iconProvider,
+		basePath:               cfg.basePath,
+		pin:                    newPinLock(cfg.pin, cfg.pinIdleTimeout, cfg.trustedProxies),
+		compareNames:           compareNames,
+	}
+	if cfg.webhookURL != "" {
+		s.webhook = newWebhook(cfg.webhookURL)
+	}
 
-func ensureSchema(db *sql.DB) error {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
+	s.version.Store(time.Now().UnixMilli())
 
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /manifest.webmanifest", s.handleManifest)
+	mux.HandleFunc("GET /sw.js", s.handleServiceWorker)
+	mux.HandleFunc("GET /assets/{name}", handleAsset)
+	mux.HandleFunc("GET /partials/dashboard", s.handleDashboard)
+	mux.HandleFunc("GET /partials/stale", s.handleStaleLinks)
+	mux.HandleFunc("GET /partials/expiring", s.handleExpiringLinks)
+	mux.HandleFunc("GET /partials/search", s.handleSearchPartial)
+	mux.HandleFunc("GET /partials/category/{id}", s.handleCategoryPartial)
+	mux.HandleFunc("GET /partials/archived-categories", s.handleArchivedCategories)
+	mux.HandleFunc("GET /partials/onboarding", s.handleOnboarding)
+	mux.HandleFunc("GET /go/{id}", s.handleGo)
+	mux.HandleFunc("GET /share/{token}", s.handleSharedCategory)
+	mux.HandleFunc("POST /go/{id}", s.handleGoConfirmed)
+	mux.HandleFunc("GET /links/{id}/icon", s.handleLinkIcon)
+	mux.HandleFunc("GET /api/backup", requireAdminLogin(cfg.adminToken, s.handleBackup))
+	mux.HandleFunc("GET /api/export/bookmarks", s.handleExportBookmarks)
+	mux.HandleFunc("GET /api/export/csv", s.handleExportCSV)
+	mux.HandleFunc("GET /api/export/opml", s.handleExportOPML)
+	mux.HandleFunc("GET /api/quickopen", s.handleQuickOpen)
+	mux.HandleFunc("GET /api/state", s.handleState)
+	mux.Handle("GET /ws", websocket.Server{Handler: s.serveLiveUpdates})
+	mux.HandleFunc("GET /api/search/recent", s.handleRecentSearches)
+	mux.HandleFunc("GET /api/integrity", requireAdminLogin(cfg.adminToken, s.handleIntegrity))
+	mux.HandleFunc("POST /api/maintenance/optimize", requireAdminToken(cfg.adminToken, s.handleOptimize))
+	mux.HandleFunc("GET /admin", requireAdminLogin(cfg.adminToken, s.handleAdmin))
+	mux.HandleFunc("GET /api/presets", s.handleListPresets)
+	mux.HandleFunc("GET /api/categories", s.handleListCategories)
+	mux.HandleFunc("GET /api/categories/{id}/links", s.handleCategoryLinkURLs)
+	mux.HandleFunc("GET /api/categories/{id}/impact", s.handleCategoryImpact)
+	mux.HandleFunc("GET /api/links/{id}/visits", s.handleLinkVisits)
+	mux.HandleFunc("GET /api/links/{id}/stats", s.handleLinkStats)
+	mux.HandleFunc("GET /api/check-url", s.handleCheckURL)
+	mux.HandleFunc("GET /api/links", s.handleListLinks)
+	mux.HandleFunc("GET /api/stats/top", s.handleTopLinks)
+	mux.HandleFunc("GET /api/audit", s.handleAuditLog)
+	mux.HandleFunc("POST /actions/panels/create", s.handleCreatePanel)
+	mux.HandleFunc("POST /actions/panels/{id}/{action}", s.handlePanelActions)
+	mux.HandleFunc("POST /actions/categories/create", s.handleCreateCategory)
+	mux.HandleFunc("POST /actions/categories/bulk-create", s.handleBulkCreateCategories)
+	mux.HandleFunc("POST /actions/categories/merge", s.handleMergeCategories)
+	mux.HandleFunc("POST /actions/categories/reorder", s.handleSetCategoryOrder)
+	mux.HandleFunc("POST /actions/settings/home-category", s.handleSetHomeCategory)
+	mux.HandleFunc("POST /actions/settings/default-category", s.handleSetDefaultCategory)
+	mux.HandleFunc("POST /actions/settings/site", s.handleSetSiteTitle)
+	mux.HandleFunc("POST /actions/settings/theme", s.handleSetTheme)
+	mux.HandleFunc("POST /actions/categories/{id}/{action}", s.handleCategoryActions)
+	mux.HandleFunc("POST /actions/links/create", s.handleCreateLink)
+	mux.HandleFunc("POST /actions/capture", s.handleCapture)
+	mux.HandleFunc("POST /actions/links/bulk-replace", s.handleBulkReplaceURLs)
+	mux.HandleFunc("POST /actions/links/{id}/{action}", s.handleLinkActions)
+	mux.HandleFunc("POST /actions/presets/create", s.handleCreatePreset)
+	mux.HandleFunc("POST /actions/presets/{id}/{action}", s.handlePresetActions)
+	mux.HandleFunc("POST /actions/import/urls", withLimits(s.importLimits, s.handleImportURLs))
+	mux.HandleFunc("POST /actions/import/csv", withLimits(s.importLimits, s.handleImportCSV))
+	mux.HandleFunc("POST /actions/import/validate", withLimits(s.importLimits, s.handleValidateImport))
+	mux.HandleFunc("POST /actions/undo", s.handleUndo)
+	mux.HandleFunc("POST /actions/unlock", s.handleUnlock)
+	mux.HandleFunc("POST /actions/lock", s.handleLock)
+	mux.HandleFunc("POST /actions/reorder/categories", s.handleReorderCategories)
+	mux.HandleFunc("POST /actions/reorder/links", s.handleReorderLinks)
+	mux.HandleFunc("POST /actions/reorder/favorites", s.handleReorderFavorites)
 
-	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS panels (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		position INTEGER NOT NULL DEFAULT 0
-	);`); err != nil {
-		return err
-	}
+	shutdownCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	for idx, name := range defaultPanels {
-		if _, err := tx.ExecContext(ctx,
-			`INSERT INTO panels(name, position)
-			 VALUES(?, ?)
-			 ON CONFLICT(name) DO UPDATE SET position = excluded.position`,
-			name, idx,
-		); err != nil {
-			return err
-		}
+	if s.webhook != nil {
+		go s.webhook.run(shutdownCtx)
 	}
-	if err := addColumnIfMissing(ctx, tx, "panels", "notes", "TEXT NOT NULL DEFAULT ''"); err != nil {
-		return err
+	if cfg.backupDir != "" {
+		go s.runScheduledBackups(shutdownCtx, cfg.backupDir, cfg.backupInterval, cfg.backupKeep)
+	}
+	if cfg.deleteExpiredLinks {
+		go s.runExpirySweeps(shutdownCtx, expirySweepInterval)
+	}
+	if cfg.gitBackupDir != "" {
+		if _, err := runGit(shutdownCtx, cfg.gitBackupDir, "rev-parse", "--is-inside-work-tree"); err != nil {
+			log.Fatalf("GIT_BACKUP_DIR must be a git work tree: %v", err)
+		}
+		go s.runGitBackups(shutdownCtx, cfg.gitBackupDir, cfg.gitBackupInterval)
 	}
 
-	workPanelID, err := findPanelIDTx(ctx, tx, "Work")
-	if err != nil {
-		return err
+	addr := cfg.listenAddr(cfg.port)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           recoverMiddleware(loggingMiddleware(corsMiddleware(s.pin.middleware(mux), cfg.corsOrigins), cfg.trustedProxies)),
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		ReadTimeout:       cfg.readTimeout,
+		WriteTimeout:      cfg.writeTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+	}
+	// Shutdown does not wait for hijacked websocket connections, so they
+	// are told to close here.
+	httpServer.RegisterOnShutdown(s.live.close)
+	servers := []*http.Server{httpServer}
+	if len(cfg.acmeDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.acmeDomains...),
+			Cache:      autocert.DirCache(cfg.acmeCacheDir),
+		}
+		addr = cfg.listenAddr("443")
+		httpServer.Addr = addr
+		httpServer.TLSConfig = manager.TLSConfig()
+		challengeServer := &http.Server{Addr: cfg.listenAddr("80"), Handler: manager.HTTPHandler(nil), ReadHeaderTimeout: cfg.readHeaderTimeout}
+		servers = append(servers, challengeServer)
+		go func() {
+			log.Printf("serving ACME challenges on http://%s", displayAddr(challengeServer.Addr))
+			if err := challengeServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+	if cfg.tlsEnabled() && cfg.httpRedirectPort != "" {
+		redirectServer := &http.Server{
+			Addr:              cfg.listenAddr(cfg.httpRedirectPort),
+			Handler:           httpsRedirectHandler(cfg.port),
+			ReadHeaderTimeout: cfg.readHeaderTimeout,
+		}
+		servers = append(servers, redirectServer)
+		go func() {
+			log.Printf("redirecting http://%s to https", displayAddr(redirectServer.Addr))
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+	go func() {
+		<-shutdownCtx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		for _, srv := range servers {
+			_ = srv.Shutdown(ctx)
+		}
+	}()
+
+	if len(cfg.acmeDomains) > 0 || cfg.tlsEnabled() {
+		if err := http2.ConfigureServer(httpServer, &http2.Server{}); err != nil {
+			log.Fatalf("enable http/2: %v", err)
+		}
 	}
 
-	if err := migrateCategoriesToPanels(ctx, tx, workPanelID); err != nil {
-		return err
+	switch {
+	case len(cfg.acmeDomains) > 0:
+		log.Printf("api listening at https://%s for %s", displayAddr(addr), strings.Join(cfg.acmeDomains, ", "))
+		err = httpServer.ListenAndServeTLS("", "")
+	case cfg.tlsEnabled():
+		log.Printf("api listening at https://%s", displayAddr(addr))
+		err = httpServer.ListenAndServeTLS(cfg.tlsCert, cfg.tlsKey)
+	default:
+		log.Printf("api listening at http://%s", displayAddr(addr))
+		err = httpServer.ListenAndServe()
 	}
-	hasLinks, err := tableExistsTx(ctx, tx, "links")
-	if err != nil {
-		return err
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
 	}
-	if !hasLinks {
-		if _, err := tx.ExecContext(ctx, `CREATE TABLE links (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			url TEXT NOT NULL,
-			description TEXT NOT NULL DEFAULT '',
-			logo_url TEXT NOT NULL DEFAULT '',
-			custom_logo_url TEXT NOT NULL DEFAULT '',
-			category_id INTEGER NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0,
-			created_at INTEGER NOT NULL DEFAULT 0,
-			updated_at INTEGER NOT NULL DEFAULT 0,
-			FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
-		);`); err != nil {
-			return err
+}
+
+// httpsRedirectHandler sends every plain-HTTP request to the same host and
+// path on the TLS port.
+func httpsRedirectHandler(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
 		}
-	}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
 
-	if err := addColumnIfMissing(ctx, tx, "links", "description", "TEXT NOT NULL DEFAULT ''"); err != nil {
-		return err
-	}
-	if err := addColumnIfMissing(ctx, tx, "links", "logo_url", "TEXT NOT NULL DEFAULT ''"); err != nil {
-		return err
+// commandTimeout bounds one-shot CLI commands, which may import or export
+// the whole database.
+const commandTimeout = 5 * time.Minute
+
+// runCommand executes an admin subcommand against the database instead of
+// starting the HTTP server.
+func runCommand(s *server, name string, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	switch name {
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		out := fs.String("o", "-", "write the export to this file instead of stdout")
+		_ = fs.Parse(args)
+		doc, err := s.buildExport(ctx)
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		w := io.Writer(os.Stdout)
+		if *out != "-" {
+			f, err := os.Create(*out)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		in := fs.String("f", "-", "read the export from this file instead of stdin")
+		_ = fs.Parse(args)
+		r := io.Reader(os.Stdin)
+		if *in != "-" {
+			f, err := os.Open(*in)
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		var doc exportDocument
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return fmt.Errorf("import: decode: %w", err)
+		}
+		added, err := s.importDocument(ctx, doc)
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		log.Printf("imported %d links", added)
+		return nil
+	case "add-link":
+		fs := flag.NewFlagSet("add-link", flag.ExitOnError)
+		panelName := fs.String("panel", "", "panel name (default: first panel)")
+		categoryName := fs.String("category", "", "category name (required)")
+		linkName := fs.String("name", "", "link name (required)")
+		linkURL := fs.String("url", "", "link URL (required)")
+		description := fs.String("description", "", "link description")
+		_ = fs.Parse(args)
+
+		categoryID, err := s.findCategoryByName(ctx, strings.TrimSpace(*panelName), strings.TrimSpace(*categoryName))
+		if err != nil {
+			return fmt.Errorf("add-link: %w", err)
+		}
+		in := linkInput{
+			Name:        strings.TrimSpace(*linkName),
+			URL:         strings.TrimSpace(*linkURL),
+			Description: strings.TrimSpace(*description),
+			CategoryID:  categoryID,
+		}
+		if errs := s.validateLinkInput(ctx, in); len(errs) > 0 {
+			return fmt.Errorf("add-link: %w", errs)
+		}
+		if err := s.checkCategoryCapacity(ctx, s.db, categoryID, 1); err != nil {
+			return fmt.Errorf("add-link: %w", err)
+		}
+		id, err := s.insertLink(ctx, s.db, in)
+		if err != nil {
+			return fmt.Errorf("add-link: %w", err)
+		}
+		log.Printf("added link %d", id)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q (want export, import, or add-link)", name)
 	}
-	if err := addColumnIfMissing(ctx, tx, "links", "custom_logo_url", "TEXT NOT NULL DEFAULT ''"); err != nil {
-		return err
+}
+
+func (s *server) findCategoryByName(ctx context.Context, panelName string, categoryName string) (int64, error) {
+	if categoryName == "" {
+		return 0, errors.New("category is required")
 	}
-	if err := addColumnIfMissing(ctx, tx, "links", "position", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+	var panelID int64
+	var err error
+	if panelName == "" {
+		panelID, err = s.resolvePanelID(ctx, 0)
+	} else {
+		err = s.db.QueryRowContext(ctx, `SELECT id FROM panels WHERE name = ?`, panelName).Scan(&panelID)
 	}
-	if err := addColumnIfMissing(ctx, tx, "links", "created_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("panel %q not found", panelName)
 	}
-	if err := addColumnIfMissing(ctx, tx, "links", "updated_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+	if err != nil {
+		return 0, err
 	}
-	if err := normalizeLinksForeignKey(ctx, tx); err != nil {
-		return err
+	var categoryID int64
+	err = s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE panel_id = ? AND name = ?`, panelID, categoryName).Scan(&categoryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("category %q not found", categoryName)
 	}
+	return categoryID, err
+}
 
-	now := time.Now().Unix()
-	if _, err := tx.ExecContext(ctx, `UPDATE links SET position = id WHERE position = 0`); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `UPDATE links SET created_at = ? WHERE created_at = 0`, now); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `UPDATE links SET updated_at = ? WHERE updated_at = 0`, now); err != nil {
-		return err
-	}
+type exportDocument struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Panels     []exportPanel `json:"panels"`
+}
 
-	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_categories_panel_position ON categories(panel_id, position)`); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_links_category_position ON links(category_id, position)`); err != nil {
-		return err
-	}
+type exportPanel struct {
+	Name       string           `json:"name"`
+	Notes      string           `json:"notes,omitempty"`
+	Categories []exportCategory `json:"categories"`
+}
 
-	if err := seedDefaultCategoriesTx(ctx, tx, workPanelID); err != nil {
-		return err
-	}
+type exportCategory struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Archived    bool         `json:"archived,omitempty"`
+	Links       []exportLink `json:"links"`
+}
 
-	return tx.Commit()
+type exportLink struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
 }
 
-func migrateCategoriesToPanels(ctx context.Context, tx *sql.Tx, defaultPanelID int64) error {
-	hasCategories, err := tableExistsTx(ctx, tx, "categories")
+func (l exportLink) input(categoryID int64) linkInput {
+	return linkInput{
+		Name:        strings.TrimSpace(l.Name),
+		URL:         strings.TrimSpace(l.URL),
+		Description: strings.TrimSpace(l.Description),
+		CategoryID:  categoryID,
+	}
+}
+
+func (s *server) buildExport(ctx context.Context) (exportDocument, error) {
+	panels, err := s.loadPanels(ctx)
 	if err != nil {
-		return err
+		return exportDocument{}, err
 	}
-	if !hasCategories {
-		if _, err := tx.ExecContext(ctx, `CREATE TABLE categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			panel_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0,
-			UNIQUE(panel_id, name),
-			FOREIGN KEY(panel_id) REFERENCES panels(id) ON DELETE CASCADE
-		);`); err != nil {
-			return err
+	doc := exportDocument{Version: 1, ExportedAt: time.Now().UTC(), Panels: make([]exportPanel, 0, len(panels))}
+	for _, p := range panels {
+		data, err := s.getDashboardData(ctx, p.ID, defaultLinkSort)
+		if err != nil {
+			return exportDocument{}, err
 		}
-		return nil
+		panel := exportPanel{Name: p.Name, Notes: data.PanelNotes, Categories: make([]exportCategory, 0, len(data.Categories))}
+		for _, c := range append(data.Categories, data.ArchivedCategories...) {
+			category := exportCategory{Name: c.Name, Description: c.Description, Archived: c.Archived, Links: make([]exportLink, 0, len(c.Links))}
+			for _, l := range c.Links {
+				category.Links = append(category.Links, exportLink{Name: l.Name, URL: l.URL, Description: l.Description})
+			}
+			panel.Categories = append(panel.Categories, category)
+		}
+		doc.Panels = append(doc.Panels, panel)
 	}
+	return doc, nil
+}
 
-	hasPanelID, err := columnExistsTx(ctx, tx, "categories", "panel_id")
+// importDocument merges an export into the database in one transaction.
+// Panels and categories are matched by name and created when missing;
+// links are always appended. Any invalid link aborts the whole import.
+func (s *server) importDocument(ctx context.Context, doc exportDocument) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	if !hasPanelID {
-		if _, err := tx.ExecContext(ctx, `ALTER TABLE categories RENAME TO categories_legacy`); err != nil {
-			return err
-		}
-		if _, err := tx.ExecContext(ctx, `CREATE TABLE categories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			panel_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			position INTEGER NOT NULL DEFAULT 0,
-			UNIQUE(panel_id, name),
-			FOREIGN KEY(panel_id) REFERENCES panels(id) ON DELETE CASCADE
-		);`); err != nil {
-			return err
+	added := 0
+	for _, p := range doc.Panels {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return 0, errors.New("panel with empty name")
 		}
 		if _, err := tx.ExecContext(ctx,
-			`INSERT INTO categories(id, panel_id, name, position)
-			 SELECT id, ?, name, id
-			 FROM categories_legacy
-			 ORDER BY id ASC`,
-			defaultPanelID,
+			`INSERT OR IGNORE INTO panels(name, position) SELECT ?, COALESCE(MAX(position), -1) + 1 FROM panels`,
+			name,
 		); err != nil {
-			return err
+			return 0, err
 		}
-		if _, err := tx.ExecContext(ctx, `DROP TABLE categories_legacy`); err != nil {
-			return err
+		panelID, err := findPanelIDTx(ctx, tx, name)
+		if err != nil {
+			return 0, err
+		}
+		if notes := strings.TrimSpace(p.Notes); notes != "" {
+			if _, err := tx.ExecContext(ctx, `UPDATE panels SET notes = ? WHERE id = ? AND notes = ''`, notes, panelID); err != nil {
+				return 0, err
+			}
+		}
+		for _, c := range p.Categories {
+			categoryID, err := s.ensureCategoryTx(ctx, tx, panelID, strings.TrimSpace(c.Name))
+			if err != nil {
+				return 0, err
+			}
+			if description := strings.TrimSpace(c.Description); description != "" {
+				if _, err := tx.ExecContext(ctx, `UPDATE categories SET description = ? WHERE id = ? AND description = ''`, description, categoryID); err != nil {
+					return 0, err
+				}
+			}
+			if c.Archived {
+				if _, err := tx.ExecContext(ctx, `UPDATE categories SET archived = 1 WHERE id = ?`, categoryID); err != nil {
+					return 0, err
+				}
+			}
+			if err := s.checkCategoryCapacity(ctx, tx, categoryID, len(c.Links)); err != nil {
+				return 0, fmt.Errorf("%s/%s: %w", name, c.Name, err)
+			}
+			for _, l := range c.Links {
+				in := l.input(categoryID)
+				if errs := in.validateFields(); len(errs) > 0 {
+					return 0, fmt.Errorf("link %q in %s/%s: %w", l.Name, name, c.Name, errs)
+				}
+				if _, err := s.insertLink(ctx, tx, in); err != nil {
+					return 0, err
+				}
+				added++
+			}
 		}
-		return nil
 	}
+	return added, tx.Commit()
+}
 
-	if err := addColumnIfMissing(ctx, tx, "categories", "position", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+// ensureCategoryTx returns the id of the named category in the panel,
+// creating it at the end of the panel when it does not exist yet. Creating
+// one past MAX_CATEGORIES fails with categoryLimitError.
+func (s *server) ensureCategoryTx(ctx context.Context, tx *sql.Tx, panelID int64, name string) (int64, error) {
+	if name == "" {
+		return 0, errors.New("category with empty name")
 	}
-	if _, err := tx.ExecContext(ctx, `UPDATE categories SET panel_id = ? WHERE panel_id IS NULL OR panel_id = 0`, defaultPanelID); err != nil {
-		return err
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE panel_id = ? AND name = ?`, panelID, name).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return id, err
 	}
-	if _, err := tx.ExecContext(ctx, `UPDATE categories SET position = id WHERE position = 0`); err != nil {
-		return err
+	if err := s.checkCategoryLimit(ctx, tx, 1); err != nil {
+		return 0, err
 	}
-	return nil
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO categories(panel_id, name, position)
+		 SELECT ?, ?, COALESCE(MAX(position), -1) + 1 FROM categories WHERE panel_id = ?`,
+		panelID, name, panelID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
 }
 
-func seedDefaultCategoriesTx(ctx context.Context, tx *sql.Tx, panelID int64) error {
-	for idx, name := range defaultCategories {
-		if _, err := tx.ExecContext(ctx,
-			`INSERT OR IGNORE INTO categories(panel_id, name, position) VALUES(?, ?, ?)`,
-			panelID, name, idx+1,
-		); err != nil {
-			return err
-		}
-	}
-	return nil
+type config struct {
+	sqlitePath string
+	port       string
+	// bindAddr is the host or IP to listen on; empty means all
+	// interfaces. IPv6 literals are stored without brackets.
+	bindAddr       string
+	backupDir      string
+	backupInterval time.Duration
+	backupKeep     int
+	// gitBackupDir is a git work tree that receives a committed JSON
+	// export every gitBackupInterval; empty disables it.
+	gitBackupDir      string
+	gitBackupInterval time.Duration
+	// tlsCert and tlsKey are file paths; both must be set to serve HTTPS.
+	tlsCert          string
+	tlsKey           string
+	httpRedirectPort string
+	// HTTP server timeouts, from the HTTP_*_TIMEOUT variables.
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	// acmeDomains enables Let's Encrypt certificates for these hosts,
+	// cached in acmeCacheDir next to the database.
+	acmeDomains  []string
+	acmeCacheDir string
+	// trustedProxies lists the reverse proxies whose forwarding headers
+	// are believed when working out the client IP.
+	trustedProxies      trustedProxies
+	maxLinksPerCategory int
+	maxCategories       int
+	// collapseThreshold auto-collapses categories showing more links
+	// than this; zero never does.
+	collapseThreshold int
+	categorySort      string
+	// orderByInsertion replaces the drag-and-drop order of categories
+	// and links with the order they were created in.
+	orderByInsertion  bool
+	autoUncategorized bool
+	// locale orders names for LOCALE; empty means ASCII case folding.
+	locale       string
+	location     *time.Location
+	importLimits routeLimits
+	// adminToken is the bearer token for admin endpoints; empty
+	// disables them.
+	adminToken string
+	// corsOrigins are the exact origins allowed to call /api/ from a
+	// browser on another origin; empty disables CORS entirely.
+	corsOrigins corsOrigins
+	// dbPassphrase derives the key that encrypts link URLs and
+	// descriptions; empty leaves them unencrypted.
+	dbPassphrase string
+	// deleteConfirmThreshold is how many items a delete may remove
+	// without confirm=true; zero never asks.
+	deleteConfirmThreshold int
+	// webhookURL receives a JSON changeEvent after each mutation;
+	// empty sends nothing.
+	webhookURL string
+	// iconProvider is the logo URL template for links without a custom
+	// logo, with {host} and {scheme} placeholders.
+	iconProvider string
+	// basePath is the path the dashboard page is served under, with a
+	// trailing slash.
+	basePath string
+	// pin is required for changes when set; pinIdleTimeout is how long an
+	// unlocked session lasts without a request.
+	pin            string
+	pinIdleTimeout time.Duration
+	// deleteExpiredLinks removes links past their expires_at in the
+	// background instead of only hiding them.
+	deleteExpiredLinks bool
+	// allowPrivateFetch lets link fetches reach private and loopback
+	// addresses.
+	allowPrivateFetch bool
 }
 
-func normalizeLinksForeignKey(ctx context.Context, tx *sql.Tx) error {
-	target, err := linksForeignKeyTargetTx(ctx, tx)
-	if err != nil {
-		return err
-	}
-	if target == "" || target == "categories" {
-		return nil
-	}
+func (c config) tlsEnabled() bool {
+	return c.tlsCert != "" && c.tlsKey != ""
+}
 
-	if _, err := tx.ExecContext(ctx, `ALTER TABLE links RENAME TO links_legacy`); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `CREATE TABLE links (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		url TEXT NOT NULL,
-		description TEXT NOT NULL DEFAULT '',
-		logo_url TEXT NOT NULL DEFAULT '',
-		custom_logo_url TEXT NOT NULL DEFAULT '',
-		category_id INTEGER NOT NULL,
-		position INTEGER NOT NULL DEFAULT 0,
-		created_at INTEGER NOT NULL DEFAULT 0,
-		updated_at INTEGER NOT NULL DEFAULT 0,
-		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
-	);`); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `INSERT INTO links(
-		id, name, url, description, logo_url, custom_logo_url, category_id, position, created_at, updated_at
-	)
-	SELECT
-		id,
-		name,
-		url,
-		COALESCE(description, ''),
-		COALESCE(logo_url, ''),
-		COALESCE(custom_logo_url, ''),
-		category_id,
-		CASE WHEN position = 0 THEN id ELSE position END,
-		CASE WHEN created_at = 0 THEN CAST(strftime('%s','now') AS INTEGER) ELSE created_at END,
-		CASE WHEN updated_at = 0 THEN CAST(strftime('%s','now') AS INTEGER) ELSE updated_at END
-	FROM links_legacy`); err != nil {
-		return err
-	}
-	if _, err := tx.ExecContext(ctx, `DROP TABLE links_legacy`); err != nil {
-		return err
-	}
-	return nil
+// listenAddr joins the bind address with port, bracketing IPv6 literals.
+func (c config) listenAddr(port string) string {
+	return net.JoinHostPort(c.bindAddr, port)
 }
 
-func linksForeignKeyTargetTx(ctx context.Context, tx *sql.Tx) (string, error) {
-	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_list(links)`)
+// displayAddr turns a listen address into something clickable for the
+// startup log, showing localhost when every interface is bound.
+func displayAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// loadEnvFile sets variables from a file of KEY=VALUE lines, leaving any
+// that are already in the environment alone so real env vars win. Blank
+// lines and lines starting with # are skipped, an "export " prefix is
+// allowed, and values may be wrapped in single or double quotes; double
+// quotes understand \n, \" and \\. Unquoted values end at " #". A missing
+// file is only an error when required.
+func loadEnvFile(path string, required bool) error {
+	raw, err := os.ReadFile(path)
 	if err != nil {
-		return "", err
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("ENV_FILE: %w", err)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			id       int
-			seq      int
-			table    string
-			fromCol  string
-			toCol    string
-			onUpdate string
-			onDelete string
-			match    string
-		)
-		if err := rows.Scan(&id, &seq, &table, &fromCol, &toCol, &onUpdate, &onDelete, &match); err != nil {
-			return "", err
+	for i, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
-		if strings.EqualFold(fromCol, "category_id") {
-			return strings.ToLower(strings.TrimSpace(table)), nil
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvKey(key) {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, i+1)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, i+1, key, err)
+		}
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, i+1, err)
 		}
 	}
-	return "", rows.Err()
+	return nil
 }
 
-func addColumnIfMissing(ctx context.Context, tx *sql.Tx, table string, column string, definition string) error {
-	exists, err := columnExistsTx(ctx, tx, table, column)
-	if err != nil {
-		return err
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
 	}
-	if exists {
-		return nil
+	for i, c := range key {
+		if c != '_' && !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
 	}
-	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
-	return err
+	return true
 }
 
-func columnExistsTx(ctx context.Context, tx *sql.Tx, table string, column string) (bool, error) {
-	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
-	if err != nil {
-		return false, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var cid int
-		var name string
-		var colType string
-		var notNull int
-		var defaultValue sql.NullString
-		var pk int
-		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
-			return false, err
+// parseEnvValue unquotes one .env value; see loadEnvFile.
+func parseEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := 0
+		for i := 1; i < len(value) && end == 0; i++ {
+			if value[i] == '\\' && quote == '"' {
+				i++
+			} else if value[i] == quote {
+				end = i
+			}
 		}
-		if strings.EqualFold(name, column) {
-			return true, nil
+		if end == 0 {
+			return "", errors.New("unterminated quote")
 		}
+		if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", errors.New("unexpected text after closing quote")
+		}
+		inner := value[1:end]
+		if quote == '"' {
+			inner = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(inner)
+		}
+		return inner, nil
 	}
-	return false, rows.Err()
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
 }
 
-func tableExistsTx(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
-	var name string
-	err := tx.QueryRowContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name = ? LIMIT 1`, table).Scan(&name)
-	if err == nil {
-		return true, nil
+func loadConfig() (config, error) {
+	envFile, required := ".env", false
+	if raw, set := os.LookupEnv("ENV_FILE"); set {
+		envFile, required = strings.TrimSpace(raw), true
 	}
-	if errors.Is(err, sql.ErrNoRows) {
-		return false, nil
+	if envFile != "" {
+		if err := loadEnvFile(envFile, required); err != nil {
+			return config{}, err
+		}
 	}
-	return false, err
-}
 
-func findPanelIDTx(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
-	var id int64
-	if err := tx.QueryRowContext(ctx, `SELECT id FROM panels WHERE name = ? LIMIT 1`, name).Scan(&id); err != nil {
-		return 0, err
+	sqlitePath := strings.TrimSpace(os.Getenv("SQLITE_PATH"))
+	port := strings.TrimSpace(os.Getenv("PORT"))
+
+	if sqlitePath == "" {
+		if _, set := os.LookupEnv("SQLITE_PATH"); set {
+			return config{}, errors.New("SQLITE_PATH is set but empty")
+		}
+		sqlitePath = "./data/personal_dash.db"
+	}
+	if port == "" {
+		port = "8080"
+	}
+	if err := validatePort("PORT", port); err != nil {
+		return config{}, err
 	}
-	return id, nil
-}
 
-func (s *server) handleHealth(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("ok"))
-}
+	cfg := config{
+		sqlitePath:     sqlitePath,
+		port:           port,
+		backupInterval: 24 * time.Hour,
+		backupKeep:     7,
 
-func (s *server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+		gitBackupInterval: 24 * time.Hour,
+		importLimits:      routeLimits{timeout: defaultImportTimeout, maxBytes: defaultImportMaxBytes},
+
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		readTimeout:       defaultReadTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		idleTimeout:       defaultIdleTimeout,
+
+		deleteConfirmThreshold: defaultDeleteConfirmThreshold,
+		pinIdleTimeout:         defaultPinIdleTimeout,
 	}
-	activePanelID := parseInt64OrZero(strings.TrimSpace(r.URL.Query().Get("panel_id")))
-	s.renderDashboard(w, activePanelID)
-}
 
-func (s *server) handleCreatePanel(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+	bindAddr, err := parseBindAddr(os.Getenv("BIND_ADDR"))
+	if err != nil {
+		return config{}, err
 	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+	cfg.bindAddr = bindAddr
+
+	cfg.backupDir = strings.TrimSpace(os.Getenv("BACKUP_DIR"))
+	if raw := strings.TrimSpace(os.Getenv("BACKUP_INTERVAL")); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return config{}, fmt.Errorf("BACKUP_INTERVAL must be a positive duration like 24h, got %q", raw)
+		}
+		cfg.backupInterval = interval
 	}
-	name := strings.TrimSpace(r.FormValue("name"))
-	if name == "" {
-		http.Error(w, "panel name is required", http.StatusBadRequest)
-		return
+	if raw := strings.TrimSpace(os.Getenv("BACKUP_KEEP")); raw != "" {
+		keep, err := strconv.Atoi(raw)
+		if err != nil || keep < 0 {
+			return config{}, fmt.Errorf("BACKUP_KEEP must be a non-negative integer, got %q", raw)
+		}
+		cfg.backupKeep = keep
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
+	cfg.gitBackupDir = strings.TrimSpace(os.Getenv("GIT_BACKUP_DIR"))
+	if raw := strings.TrimSpace(os.Getenv("GIT_BACKUP_INTERVAL")); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return config{}, fmt.Errorf("GIT_BACKUP_INTERVAL must be a positive duration like 24h, got %q", raw)
+		}
+		cfg.gitBackupInterval = interval
+	}
 
-	var nextPos int
-	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM panels`).Scan(&nextPos); err != nil {
-		http.Error(w, "failed to create panel", http.StatusInternalServerError)
-		return
+	cfg.tlsCert = strings.TrimSpace(os.Getenv("TLS_CERT"))
+	cfg.tlsKey = strings.TrimSpace(os.Getenv("TLS_KEY"))
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		return config{}, errors.New("TLS_CERT and TLS_KEY must be set together")
+	}
+	cfg.httpRedirectPort = strings.TrimSpace(os.Getenv("HTTP_REDIRECT_PORT"))
+	if cfg.httpRedirectPort != "" {
+		if !cfg.tlsEnabled() {
+			return config{}, errors.New("HTTP_REDIRECT_PORT requires TLS_CERT and TLS_KEY")
+		}
+		if err := validatePort("HTTP_REDIRECT_PORT", cfg.httpRedirectPort); err != nil {
+			return config{}, err
+		}
 	}
 
-	res, err := s.db.ExecContext(ctx, `INSERT INTO panels(name, position) VALUES(?, ?)`, name, nextPos)
-	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "unique") {
-			http.Error(w, "panel already exists", http.StatusConflict)
-			return
+	for _, domain := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.acmeDomains = append(cfg.acmeDomains, domain)
 		}
-		http.Error(w, "failed to create panel", http.StatusInternalServerError)
-		return
 	}
-	newID, _ := res.LastInsertId()
-	s.renderDashboard(w, newID)
-}
+	if len(cfg.acmeDomains) > 0 {
+		if cfg.tlsEnabled() {
+			return config{}, errors.New("ACME_DOMAINS cannot be combined with TLS_CERT/TLS_KEY")
+		}
+		dataDir := "./data"
+		if dbFile := sqliteFilePath(cfg.sqlitePath); dbFile != "" {
+			dataDir = filepath.Dir(dbFile)
+		}
+		cfg.acmeCacheDir = filepath.Join(dataDir, "autocert")
+	}
 
-func (s *server) handlePanelActions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+	if raw := strings.TrimSpace(os.Getenv("MAX_LINKS_PER_CATEGORY")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return config{}, fmt.Errorf("MAX_LINKS_PER_CATEGORY must be a non-negative integer, got %q", raw)
+		}
+		cfg.maxLinksPerCategory = limit
 	}
-	path := strings.TrimPrefix(r.URL.Path, "/actions/panels/")
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) < 2 {
-		http.NotFound(w, r)
-		return
+	if raw := strings.TrimSpace(os.Getenv("MAX_CATEGORIES")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return config{}, fmt.Errorf("MAX_CATEGORIES must be a non-negative integer, got %q", raw)
+		}
+		cfg.maxCategories = limit
 	}
-	panelID := parseInt64OrZero(parts[0])
-	if panelID == 0 {
-		http.Error(w, "invalid panel id", http.StatusBadRequest)
-		return
+	if raw := strings.TrimSpace(os.Getenv("DELETE_CONFIRM_THRESHOLD")); raw != "" {
+		threshold, err := strconv.Atoi(raw)
+		if err != nil || threshold < 0 {
+			return config{}, fmt.Errorf("DELETE_CONFIRM_THRESHOLD must be a non-negative integer, got %q", raw)
+		}
+		cfg.deleteConfirmThreshold = threshold
 	}
-	switch parts[1] {
-	case "delete":
-		s.handleDeletePanel(w, r, panelID)
-	case "notes":
-		s.handleUpdatePanelNotes(w, r, panelID)
-	case "notes-clear":
-		s.handleClearPanelNotes(w, r, panelID)
-	default:
-		http.NotFound(w, r)
+	if raw := strings.TrimSpace(os.Getenv("COLLAPSE_THRESHOLD")); raw != "" {
+		threshold, err := strconv.Atoi(raw)
+		if err != nil || threshold < 0 {
+			return config{}, fmt.Errorf("COLLAPSE_THRESHOLD must be a non-negative integer, got %q", raw)
+		}
+		cfg.collapseThreshold = threshold
 	}
-}
-
-func (s *server) handleDeletePanel(w http.ResponseWriter, r *http.Request, panelID int64) {
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
 
-	var count int
-	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM panels`).Scan(&count); err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-		return
+	if raw := strings.TrimSpace(os.Getenv("IMPORT_TIMEOUT")); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return config{}, fmt.Errorf("IMPORT_TIMEOUT must be a positive duration like 2m, got %q", raw)
+		}
+		cfg.importLimits.timeout = timeout
 	}
-	if count <= 1 {
-		http.Error(w, "at least one panel is required", http.StatusBadRequest)
-		return
+	if raw := strings.TrimSpace(os.Getenv("IMPORT_MAX_BYTES")); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit <= 0 {
+			return config{}, fmt.Errorf("IMPORT_MAX_BYTES must be a positive integer, got %q", raw)
+		}
+		cfg.importLimits.maxBytes = limit
 	}
 
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-		return
+	for _, setting := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.readHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", &cfg.readTimeout},
+		{"HTTP_WRITE_TIMEOUT", &cfg.writeTimeout},
+		{"HTTP_IDLE_TIMEOUT", &cfg.idleTimeout},
+	} {
+		if raw := strings.TrimSpace(os.Getenv(setting.name)); raw != "" {
+			timeout, err := time.ParseDuration(raw)
+			if err != nil || timeout <= 0 {
+				return config{}, fmt.Errorf("%s must be a positive duration like 30s, got %q", setting.name, raw)
+			}
+			*setting.value = timeout
+		}
+	}
+	if cfg.writeTimeout <= cfg.importLimits.timeout {
+		return config{}, fmt.Errorf("HTTP_WRITE_TIMEOUT (%s) must be longer than IMPORT_TIMEOUT (%s)", cfg.writeTimeout, cfg.importLimits.timeout)
 	}
-	defer tx.Rollback()
 
-	catRows, err := tx.QueryContext(ctx, `SELECT id FROM categories WHERE panel_id = ?`, panelID)
-	if err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+	cfg.adminToken = strings.TrimSpace(os.Getenv("ADMIN_TOKEN"))
+	cfg.pin = strings.TrimSpace(os.Getenv("PIN"))
+	if cfg.pin != "" && len(cfg.pin) < minPinLength {
+		return config{}, fmt.Errorf("PIN must be at least %d characters", minPinLength)
+	}
+	if raw := strings.TrimSpace(os.Getenv("PIN_IDLE_TIMEOUT")); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return config{}, fmt.Errorf("PIN_IDLE_TIMEOUT must be a positive duration like 5m, got %q", raw)
+		}
+		cfg.pinIdleTimeout = timeout
+	}
+	switch raw := strings.TrimSpace(os.Getenv("EXPIRED_LINKS")); raw {
+	case "", "hide":
+	case "delete":
+		cfg.deleteExpiredLinks = true
+	default:
+		return config{}, fmt.Errorf("EXPIRED_LINKS must be hide or delete, got %q", raw)
+	}
+	cfg.basePath = "/"
+	if raw := strings.TrimSpace(os.Getenv("BASE_PATH")); raw != "" {
+		if !strings.HasPrefix(raw, "/") || strings.ContainsAny(raw, "?#") {
+			return config{}, fmt.Errorf("BASE_PATH must be a path starting with /, got %q", raw)
+		}
+		cfg.basePath = strings.TrimSuffix(raw, "/") + "/"
+	}
+	provider, err := parseIconProvider(os.Getenv("ICON_PROVIDER"))
+	if err != nil {
+		return config{}, err
+	}
+	cfg.iconProvider = provider
+	if raw := strings.TrimSpace(os.Getenv("WEBHOOK_URL")); raw != "" {
+		parsed, err := url.Parse(raw)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return config{}, fmt.Errorf("WEBHOOK_URL must be an http or https URL, got %q", raw)
+		}
+		cfg.webhookURL = raw
+	}
+	// Not trimmed: spaces may be part of the passphrase.
+	cfg.dbPassphrase = os.Getenv("DB_PASSPHRASE")
+
+	// TZ is left to the Go runtime, which already applies it to time.Local
+	// and accepts POSIX rules like UTC0 that LoadLocation does not.
+	cfg.location = time.Local
+	if raw := strings.TrimSpace(os.Getenv("DASH_TZ")); raw != "" {
+		location, err := time.LoadLocation(raw)
+		if err != nil {
+			return config{}, fmt.Errorf("DASH_TZ must be an IANA time zone like Europe/Berlin, got %q", raw)
+		}
+		cfg.location = location
+	}
+
+	cfg.locale = defaultLocale
+	if raw := strings.TrimSpace(os.Getenv("LOCALE")); raw != "" {
+		if _, err := language.Parse(raw); err != nil {
+			log.Printf("LOCALE %q is not a language tag, sorting names by ASCII case folding", raw)
+			cfg.locale = ""
+		} else {
+			cfg.locale = raw
+		}
+	}
+
+	cfg.categorySort = defaultCategorySort
+	if raw := strings.TrimSpace(os.Getenv("CATEGORY_SORT")); raw != "" {
+		if _, ok := categorySortOrders[raw]; !ok {
+			return config{}, fmt.Errorf("CATEGORY_SORT must be position, name_asc, or name_desc, got %q", raw)
+		}
+		cfg.categorySort = raw
+	}
+	if raw := strings.TrimSpace(os.Getenv("ORDER_BY_INSERTION")); raw != "" {
+		on, err := strconv.ParseBool(raw)
+		if err != nil {
+			return config{}, fmt.Errorf("ORDER_BY_INSERTION must be true or false, got %q", raw)
+		}
+		cfg.orderByInsertion = on
+	}
+	if raw := strings.TrimSpace(os.Getenv("AUTO_UNCATEGORIZED")); raw != "" {
+		on, err := strconv.ParseBool(raw)
+		if err != nil {
+			return config{}, fmt.Errorf("AUTO_UNCATEGORIZED must be true or false, got %q", raw)
+		}
+		cfg.autoUncategorized = on
+	}
+
+	proxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXY"))
+	if err != nil {
+		return config{}, err
+	}
+	cfg.trustedProxies = proxies
+	if raw := strings.TrimSpace(os.Getenv("ALLOW_PRIVATE_FETCH")); raw != "" {
+		on, err := strconv.ParseBool(raw)
+		if err != nil {
+			return config{}, fmt.Errorf("ALLOW_PRIVATE_FETCH must be true or false, got %q", raw)
+		}
+		cfg.allowPrivateFetch = on
+	}
+
+	origins, err := parseCORSOrigins(os.Getenv("CORS_ORIGINS"))
+	if err != nil {
+		return config{}, err
+	}
+	cfg.corsOrigins = origins
+
+	return cfg, nil
+}
+
+// parseBindAddr accepts a hostname, an IPv4 address, or an IPv6 address
+// with or without brackets, and returns it in the form net.JoinHostPort
+// expects.
+func parseBindAddr(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		value = value[1 : len(value)-1]
+	}
+	if _, err := netip.ParseAddr(value); err == nil {
+		return value, nil
+	}
+	if strings.ContainsAny(value, ":[]/ ") {
+		return "", fmt.Errorf("BIND_ADDR must be a host name or IP address without a port, got %q", raw)
+	}
+	return value, nil
+}
+
+func validatePort(name string, value string) error {
+	portNum, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be numeric, got %q", name, value)
+	}
+	if portNum < 1 || portNum > 65535 {
+		return fmt.Errorf("%s must be between 1 and 65535, got %d", name, portNum)
+	}
+	return nil
+}
+
+// sqliteFilePath returns the on-disk file behind SQLITE_PATH. Plain paths
+// are returned unchanged; "file:" DSNs (passed to the driver verbatim so
+// they can carry their own _pragma options) have their scheme and query
+// stripped. In-memory databases have no file and yield "".
+func sqliteFilePath(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		return dsn
+	}
+	path := strings.TrimPrefix(dsn, "file:")
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path
+}
+
+func ensureSchema(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS panels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		position INTEGER NOT NULL DEFAULT 0
+	);`); err != nil {
+		return err
+	}
+
+	for idx, name := range defaultPanels {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO panels(name, position)
+			 VALUES(?, ?)
+			 ON CONFLICT(name) DO UPDATE SET position = excluded.position`,
+			name, idx,
+		); err != nil {
+			return err
+		}
+	}
+	if err := addColumnIfMissing(ctx, tx, "panels", "notes", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	workPanelID, err := findPanelIDTx(ctx, tx, "Work")
+	if err != nil {
+		return err
+	}
+
+	if err := migrateCategoriesToPanels(ctx, tx, workPanelID); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "categories", "collapsed", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "categories", "description", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	hasLinks, err := tableExistsTx(ctx, tx, "links")
+	if err != nil {
+		return err
+	}
+	if !hasLinks {
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			logo_url TEXT NOT NULL DEFAULT '',
+			custom_logo_url TEXT NOT NULL DEFAULT '',
+			category_id INTEGER NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT 0,
+			updated_at INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+		);`); err != nil {
+			return err
+		}
+	}
+
+	if err := addColumnIfMissing(ctx, tx, "links", "description", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "logo_url", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "custom_logo_url", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "position", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "created_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "updated_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := normalizeLinksForeignKey(ctx, tx); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "click_count", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "last_opened_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "target_blank", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "confirm", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, column := range []string{"og_title", "og_description", "og_image"} {
+		if err := addColumnIfMissing(ctx, tx, "links", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	for _, column := range []string{"last_status", "last_checked"} {
+		if err := addColumnIfMissing(ctx, tx, "links", column, "INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if err := addColumnIfMissing(ctx, tx, "categories", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	for _, column := range []string{"visible_from", "visible_to", "hotkey"} {
+		if err := addColumnIfMissing(ctx, tx, "links", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "expires_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(ctx, tx, "links", "enabled", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS presets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		links TEXT NOT NULL DEFAULT '[]',
+		created_at INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL DEFAULT 0
+	);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS link_icons (
+		link_id INTEGER PRIMARY KEY,
+		content_type TEXT NOT NULL,
+		data BLOB NOT NULL,
+		updated_at INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(link_id) REFERENCES links(id) ON DELETE CASCADE
+	);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS search_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		query TEXT NOT NULL,
+		searched_at INTEGER NOT NULL DEFAULT 0
+	);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS link_visits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		link_id INTEGER NOT NULL,
+		visited_at INTEGER NOT NULL,
+		FOREIGN KEY(link_id) REFERENCES links(id) ON DELETE CASCADE
+	);`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_link_visits_link ON link_visits(link_id, id)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		details_json TEXT NOT NULL DEFAULT '{}',
+		created_at INTEGER NOT NULL,
+		hash TEXT NOT NULL
+	);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS favorites (
+		link_id INTEGER PRIMARY KEY,
+		position INTEGER NOT NULL,
+		FOREIGN KEY(link_id) REFERENCES links(id) ON DELETE CASCADE
+	);`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS category_shares (
+		token TEXT PRIMARY KEY,
+		category_id INTEGER NOT NULL,
+		created_at INTEGER NOT NULL,
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_category_shares_category ON category_shares(category_id)`); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);`); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if _, err := tx.ExecContext(ctx, `UPDATE links SET position = id WHERE position = 0`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE links SET created_at = ? WHERE created_at = 0`, now); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE links SET updated_at = ? WHERE updated_at = 0`, now); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_categories_panel_position ON categories(panel_id, position)`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_links_category_position ON links(category_id, position)`); err != nil {
+		return err
+	}
+
+	if err := seedDefaultCategoriesTx(ctx, tx, workPanelID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func migrateCategoriesToPanels(ctx context.Context, tx *sql.Tx, defaultPanelID int64) error {
+	hasCategories, err := tableExistsTx(ctx, tx, "categories")
+	if err != nil {
+		return err
+	}
+	if !hasCategories {
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			panel_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			UNIQUE(panel_id, name),
+			FOREIGN KEY(panel_id) REFERENCES panels(id) ON DELETE CASCADE
+		);`); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	hasPanelID, err := columnExistsTx(ctx, tx, "categories", "panel_id")
+	if err != nil {
+		return err
+	}
+
+	if !hasPanelID {
+		if _, err := tx.ExecContext(ctx, `ALTER TABLE categories RENAME TO categories_legacy`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE categories (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			panel_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			UNIQUE(panel_id, name),
+			FOREIGN KEY(panel_id) REFERENCES panels(id) ON DELETE CASCADE
+		);`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO categories(id, panel_id, name, position)
+			 SELECT id, ?, name, id
+			 FROM categories_legacy
+			 ORDER BY id ASC`,
+			defaultPanelID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DROP TABLE categories_legacy`); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if err := addColumnIfMissing(ctx, tx, "categories", "position", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE categories SET panel_id = ? WHERE panel_id IS NULL OR panel_id = 0`, defaultPanelID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE categories SET position = id WHERE position = 0`); err != nil {
+		return err
+	}
+	return nil
+}
+
+func seedDefaultCategoriesTx(ctx context.Context, tx *sql.Tx, panelID int64) error {
+	for idx, name := range defaultCategories {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO categories(panel_id, name, position) VALUES(?, ?, ?)`,
+			panelID, name, idx+1,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizeLinksForeignKey(ctx context.Context, tx *sql.Tx) error {
+	target, err := linksForeignKeyTargetTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if target == "" || target == "categories" {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE links RENAME TO links_legacy`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		logo_url TEXT NOT NULL DEFAULT '',
+		custom_logo_url TEXT NOT NULL DEFAULT '',
+		category_id INTEGER NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO links(
+		id, name, url, description, logo_url, custom_logo_url, category_id, position, created_at, updated_at
+	)
+	SELECT
+		id,
+		name,
+		url,
+		COALESCE(description, ''),
+		COALESCE(logo_url, ''),
+		COALESCE(custom_logo_url, ''),
+		category_id,
+		CASE WHEN position = 0 THEN id ELSE position END,
+		CASE WHEN created_at = 0 THEN CAST(strftime('%s','now') AS INTEGER) ELSE created_at END,
+		CASE WHEN updated_at = 0 THEN CAST(strftime('%s','now') AS INTEGER) ELSE updated_at END
+	FROM links_legacy`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DROP TABLE links_legacy`); err != nil {
+		return err
+	}
+	return nil
+}
+
+func linksForeignKeyTargetTx(ctx context.Context, tx *sql.Tx) (string, error) {
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_list(links)`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id       int
+			seq      int
+			table    string
+			fromCol  string
+			toCol    string
+			onUpdate string
+			onDelete string
+			match    string
+		)
+		if err := rows.Scan(&id, &seq, &table, &fromCol, &toCol, &onUpdate, &onDelete, &match); err != nil {
+			return "", err
+		}
+		if strings.EqualFold(fromCol, "category_id") {
+			return strings.ToLower(strings.TrimSpace(table)), nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// isUniqueViolation reports whether err is SQLite rejecting a write for
+// breaking a UNIQUE constraint, going by the driver's extended result code
+// rather than the message text.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// isConstraintViolation reports whether err is SQLite rejecting a write
+// for breaking any constraint: UNIQUE, FOREIGN KEY, CHECK or NOT NULL.
+func isConstraintViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT
+}
+
+// isBusy reports whether err is SQLite finding the database locked by
+// another connection, such as a CLI command or an external tool, which
+// may clear up on its own. Extended codes like SQLITE_BUSY_SNAPSHOT count.
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
+
+// Busy retry limits: an operation failing with isBusy is tried up to
+// busyRetryAttempts times, first waiting busyRetryDelay and then doubling
+// it, as long as the wait ends before ctx's deadline.
+const (
+	busyRetryAttempts = 5
+	busyRetryDelay    = 20 * time.Millisecond
+)
+
+// retryBusy runs op until it succeeds, fails with anything but a busy
+// error, or runs out of attempts or time; it returns op's last error.
+func retryBusy(ctx context.Context, op func() error) error {
+	delay := busyRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !isBusy(err) || attempt == busyRetryAttempts {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// execRetry is s.db.ExecContext under retryBusy, for single-statement
+// writes outside a transaction.
+func (s *server) execRetry(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	var res sql.Result
+	err := retryBusy(ctx, func() error {
+		var err error
+		res, err = s.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func addColumnIfMissing(ctx context.Context, tx *sql.Tx, table string, column string, definition string) error {
+	exists, err := columnExistsTx(ctx, tx, table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func columnExistsTx(ctx context.Context, tx *sql.Tx, table string, column string) (bool, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name string
+		var colType string
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func tableExistsTx(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	var name string
+	err := tx.QueryRowContext(ctx, `SELECT name FROM sqlite_master WHERE type='table' AND name = ? LIMIT 1`, table).Scan(&name)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return false, err
+}
+
+func findPanelIDTx(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	var id int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM panels WHERE name = ? LIMIT 1`, name).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleDashboard renders the dashboard partial, or answers with the same
+// data as JSON when the Accept header prefers application/json.
+func (s *server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	activePanelID := parseInt64OrZero(strings.TrimSpace(r.URL.Query().Get("panel_id")))
+	sortKey := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if sortKey == "" {
+		sortKey = defaultLinkSort
+	}
+	if _, ok := linkSortOrders[sortKey]; !ok {
+		http.Error(w, "sort must be one of position, name, recent, popular", http.StatusBadRequest)
+		return
+	}
+	view := strings.TrimSpace(r.URL.Query().Get("view"))
+	if view == "" {
+		view = defaultDashboardView
+	}
+	if view != "detailed" && view != "compact" {
+		http.Error(w, "view must be detailed or compact", http.StatusBadRequest)
+		return
+	}
+	version := s.version.Load()
+	data, err := s.getDashboardData(r.Context(), activePanelID, sortKey)
+	if err != nil {
+		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+	data, hidden := data.visibleAt(s.now(), s.collapseThreshold)
+	format := "html"
+	if prefersJSON(r) {
+		format = "json"
+	}
+	// hidden changes as links enter and leave their visibility windows,
+	// which no mutation records, so it is part of the tag.
+	etag := fmt.Sprintf(`"%d-%d-%s-%s-%x-%s"`, version, activePanelID, sortKey, view, hidden, format)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Add("Vary", "Accept")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	data.View = view
+	if format == "json" {
+		writeJSON(w, http.StatusOK, data)
+		return
+	}
+	s.writeDashboard(w, data)
+}
+
+// prefersJSON reports whether the Accept header ranks application/json
+// above HTML. Wildcards count for HTML, so browsers, htmx and clients
+// that send no Accept header keep getting the partial.
+func prefersJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html", "text/*", "*/*":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// pwaAssets holds the files behind the installable web app: the icons
+// the manifest lists and the service worker script.
+//
+//go:embed assets/icon-192.png assets/icon-512.png assets/sw.js
+var pwaAssets embed.FS
+
+type manifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose"`
+}
+
+// handleManifest serves the web app manifest. Icon paths are relative,
+// so they resolve next to the manifest wherever the API is proxied;
+// start_url and scope are BASE_PATH, where the page itself lives.
+func (s *server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	manifest := map[string]any{
+		"name":             "Personal Dashboard",
+		"short_name":       "Dashboard",
+		"start_url":        s.basePath,
+		"scope":            s.basePath,
+		"display":          "standalone",
+		"background_color": "#415ebf",
+		"theme_color":      "#3d6aff",
+		"icons": []manifestIcon{
+			{Src: "assets/icon-192.png", Sizes: "192x192", Type: "image/png", Purpose: "any maskable"},
+			{Src: "assets/icon-512.png", Sizes: "512x512", Type: "image/png", Purpose: "any maskable"},
+		},
+	}
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	_ = json.NewEncoder(w).Encode(manifest)
+}
+
+// handleAsset serves one embedded file by name.
+func handleAsset(w http.ResponseWriter, r *http.Request) {
+	name := "assets/" + r.PathValue("name")
+	if _, err := fs.Stat(pwaAssets, name); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, pwaAssets, name)
+}
+
+// handleServiceWorker serves the offline-shell service worker. The
+// script sits next to the API but has to control the page at BASE_PATH,
+// which Service-Worker-Allowed permits.
+func (s *server) handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	script, err := pwaAssets.ReadFile("assets/sw.js")
+	if err != nil {
+		http.Error(w, "failed to load service worker", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Service-Worker-Allowed", s.basePath)
+	_, _ = w.Write(script)
+}
+
+// now is the current time in the configured time zone.
+func (s *server) now() time.Time {
+	if s.location == nil {
+		return time.Now()
+	}
+	return time.Now().In(s.location)
+}
+
+// markChanged records a successful mutation so cached dashboard
+// responses are invalidated, and reports it to WEBHOOK_URL if set.
+func (s *server) markChanged(event changeEvent) {
+	s.live.notify(s.version.Add(1))
+	event.At = time.Now().UTC()
+	s.webhook.send(event)
+}
+
+// Live update timings. A client that cannot take a message within
+// liveWriteTimeout is dropped; liveHeartbeat keeps idle connections from
+// being closed by proxies.
+const (
+	liveHeartbeat    = 30 * time.Second
+	liveWriteTimeout = 10 * time.Second
+)
+
+// changeHub fans version changes out to /ws connections. Each subscriber
+// has a one-slot channel that only ever holds the newest version, so
+// notify never blocks a mutation: a client that falls behind gets one
+// reload for many changes.
+type changeHub struct {
+	mu     sync.Mutex
+	subs   map[chan int64]struct{}
+	closed bool
+}
+
+// subscribe registers a listener; ok is false once the hub is closed.
+func (h *changeHub) subscribe() (ch chan int64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, false
+	}
+	if h.subs == nil {
+		h.subs = make(map[chan int64]struct{})
+	}
+	ch = make(chan int64, 1)
+	h.subs[ch] = struct{}{}
+	return ch, true
+}
+
+func (h *changeHub) unsubscribe(ch chan int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+func (h *changeHub) notify(version int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- version
+	}
+}
+
+// close ends every subscription by closing its channel.
+func (h *changeHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		close(ch)
+	}
+	h.subs = nil
+}
+
+// liveMessage is what /ws sends: "reload" with the new data version after
+// a change, or "ping" as a heartbeat.
+type liveMessage struct {
+	Type    string `json:"type"`
+	Version int64  `json:"version,omitempty"`
+}
+
+// serveLiveUpdates holds a /ws connection open, sending a reload message
+// whenever the data version changes. Messages from the client are read
+// and ignored, which is how a closed connection is noticed.
+func (s *server) serveLiveUpdates(conn *websocket.Conn) {
+	defer conn.Close()
+	// The server's read and write timeouts still apply to the hijacked
+	// connection; it is idle on purpose, so they are lifted here.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+	updates, ok := s.live.subscribe()
+	if !ok {
+		return
+	}
+	defer s.live.unsubscribe(updates)
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		var discard string
+		for websocket.Message.Receive(conn, &discard) == nil {
+		}
+	}()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var msg liveMessage
+		select {
+		case <-gone:
+			return
+		case version, ok := <-updates:
+			if !ok {
+				return
+			}
+			msg = liveMessage{Type: "reload", Version: version}
+		case <-heartbeat.C:
+			msg = liveMessage{Type: "ping"}
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout)); err != nil {
+			return
+		}
+		if err := websocket.JSON.Send(conn, msg); err != nil {
+			return
+		}
+	}
+}
+
+// changeEvent is the JSON body posted to WEBHOOK_URL. Type is
+// "<thing>.<verb>", such as link.created; ID is the affected row, left
+// out for changes that touch many rows.
+type changeEvent struct {
+	Type string    `json:"type"`
+	ID   int64     `json:"id,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// Webhook delivery limits. The queue absorbs bursts of edits; events
+// beyond it are dropped and logged rather than slowing requests down.
+const (
+	webhookQueueSize   = 100
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 3
+	webhookRetryDelay  = 2 * time.Second
+)
+
+// webhook posts change events to one URL from a single background
+// worker, so requests only pay for a channel send. A nil *webhook
+// discards events.
+type webhook struct {
+	url    string
+	client *http.Client
+	events chan changeEvent
+}
+
+func newWebhook(target string) *webhook {
+	return &webhook{
+		url:    target,
+		client: &http.Client{Timeout: webhookTimeout},
+		events: make(chan changeEvent, webhookQueueSize),
+	}
+}
+
+func (h *webhook) send(event changeEvent) {
+	if h == nil {
+		return
+	}
+	select {
+	case h.events <- event:
+	default:
+		log.Printf("webhook: queue full, dropped %s event", event.Type)
+	}
+}
+
+// run delivers queued events in order until ctx is done.
+func (h *webhook) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-h.events:
+			h.deliver(ctx, event)
+		}
+	}
+}
+
+// deliver posts event, retrying with a growing delay on network errors
+// and non-2xx answers. Failures are only logged.
+func (h *webhook) deliver(ctx context.Context, event changeEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("webhook: encode %s event: %v", event.Type, err)
+		return
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = h.post(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookMaxAttempts {
+			log.Printf("webhook: giving up on %s event after %d attempts: %v", event.Type, attempt, err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (h *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxFetchBytes))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *server) handleCreatePanel(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "panel name is required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		http.Error(w, fmt.Sprintf("panel name must be at most %d characters", maxNameLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var nextPos int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM panels`).Scan(&nextPos); err != nil {
+		http.Error(w, "failed to create panel", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := s.execRetry(ctx, `INSERT INTO panels(name, position) VALUES(?, ?)`, name, nextPos)
+	if err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, "panel already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to create panel", http.StatusInternalServerError)
+		return
+	}
+	newID, _ := res.LastInsertId()
+	s.markChanged(changeEvent{Type: "panel.created", ID: newID})
+	s.renderDashboard(w, newID)
+}
+
+func (s *server) handlePanelActions(w http.ResponseWriter, r *http.Request) {
+	panelID := parseInt64OrZero(r.PathValue("id"))
+	if panelID == 0 {
+		http.Error(w, "invalid panel id", http.StatusBadRequest)
+		return
+	}
+	switch r.PathValue("action") {
+	case "delete":
+		s.handleDeletePanel(w, r, panelID)
+	case "notes":
+		s.handleUpdatePanelNotes(w, r, panelID)
+	case "notes-clear":
+		s.handleClearPanelNotes(w, r, panelID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *server) handleDeletePanel(w http.ResponseWriter, r *http.Request, panelID int64) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM panels`).Scan(&count); err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	if count <= 1 {
+		http.Error(w, "at least one panel is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	catRows, err := tx.QueryContext(ctx, `SELECT id FROM categories WHERE panel_id = ?`, panelID)
+	if err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	catIDs := make([]int64, 0, 16)
+	for catRows.Next() {
+		var id int64
+		if err := catRows.Scan(&id); err != nil {
+			catRows.Close()
+			http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+			return
+		}
+		catIDs = append(catIDs, id)
+	}
+	catRows.Close()
+
+	summary := deleteSummary{Categories: len(catIDs)}
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM links WHERE category_id IN (SELECT id FROM categories WHERE panel_id = ?)`, panelID,
+	).Scan(&summary.Links); err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	if !s.deleteConfirmed(w, r, summary) {
+		return
+	}
+	var panelName string
+	if err := tx.QueryRowContext(ctx, `SELECT name FROM panels WHERE id = ?`, panelID).Scan(&panelName); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+
+	for _, id := range catIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE category_id = ?`, id); err != nil {
+			http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE panel_id = ?`, panelID); err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM panels WHERE id = ?`, panelID)
+	if err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		details := map[string]any{"name": panelName, "categories": summary.Categories, "links": summary.Links}
+		if err := recordAudit(ctx, tx, "delete", "panel", panelID, details); err != nil {
+			http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
+		return
+	}
+	if s.autoUncategorized {
+		// The fallback may have lived on this panel; put it back on
+		// whichever panel is now first.
+		if _, err := s.ensureUncategorized(ctx); err != nil {
+			log.Printf("recreate %s category: %v", uncategorizedName, err)
+		}
+	}
+	s.markChanged(changeEvent{Type: "panel.deleted", ID: panelID})
+
+	s.renderDashboard(w, 0)
+}
+
+func (s *server) handleUpdatePanelNotes(w http.ResponseWriter, r *http.Request, panelID int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	notes := strings.TrimSpace(r.FormValue("notes"))
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		http.Error(w, fmt.Sprintf("notes must be at most %d characters", maxNotesLength), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	if _, err := s.execRetry(ctx, `UPDATE panels SET notes = ? WHERE id = ?`, notes, panelID); err != nil {
+		http.Error(w, "failed to save notes", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "panel.updated", ID: panelID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleClearPanelNotes(w http.ResponseWriter, r *http.Request, panelID int64) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	if _, err := s.execRetry(ctx, `UPDATE panels SET notes = '' WHERE id = ?`, panelID); err != nil {
+		http.Error(w, "failed to clear notes", http.StatusInternalServerError)
+		return
+	}
+	s.recordAuditLogged(ctx, "clear_notes", "panel", panelID, nil)
+	s.markChanged(changeEvent{Type: "panel.updated", ID: panelID})
+	s.renderDashboard(w, panelID)
+}
+
+func (s *server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parseCategoryInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if errs := in.validate(); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	activePanelID, err := s.resolvePanelID(ctx, in.ActivePanelID)
+	if err != nil {
+		http.Error(w, "panel not found", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.checkCategoryLimit(ctx, s.db, 1); err != nil {
+		writeCapacityError(w, err, "failed to create category")
+		return
+	}
+
+	var nextPos int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM categories WHERE panel_id = ?`, activePanelID).Scan(&nextPos); err != nil {
+		http.Error(w, "failed to create category", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := s.execRetry(ctx,
+		`INSERT INTO categories(panel_id, name, description, position) VALUES(?, ?, ?, ?)`,
+		activePanelID, in.Name, in.Description, nextPos,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"name": "category already exists in this panel"})
+			return
+		}
+		http.Error(w, "failed to create category", http.StatusInternalServerError)
+		return
+	}
+	newID, _ := res.LastInsertId()
+	s.markChanged(changeEvent{Type: "category.created", ID: newID})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusCreated, map[string]string{"id": strconv.FormatInt(newID, 10)})
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+// handleDuplicateCategory copies a category and all of its links, in
+// their order, to a new category named name at the end of the same panel.
+// The description is copied unless a new one is given. Copies start with
+// no clicks, visits, check results or hotkeys, like duplicated links;
+// custom icons come along.
+func (s *server) handleDuplicateCategory(w http.ResponseWriter, r *http.Request, sourceID int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parseCategoryInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if errs := in.validate(); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var panelID int64
+	var description string
+	if err := tx.QueryRowContext(ctx, `SELECT panel_id, description FROM categories WHERE id = ?`, sourceID).Scan(&panelID, &description); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	if in.Description != "" {
+		description = in.Description
+	}
+	if err := s.checkCategoryLimit(ctx, tx, 1); err != nil {
+		writeCapacityError(w, err, "failed to duplicate category")
+		return
+	}
+	var nextPos int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM categories WHERE panel_id = ?`, panelID).Scan(&nextPos); err != nil {
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO categories(panel_id, name, description, position) VALUES(?, ?, ?, ?)`,
+		panelID, in.Name, description, nextPos,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"name": "category already exists in this panel"})
+			return
+		}
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	newID, _ := res.LastInsertId()
+
+	linkIDs, err := linkOrderTx(ctx, tx, sourceID, 0)
+	if err != nil {
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	if err := s.checkCategoryCapacity(ctx, tx, newID, len(linkIDs)); err != nil {
+		writeCapacityError(w, err, "failed to duplicate category")
+		return
+	}
+	now := time.Now().Unix()
+	for position, linkID := range linkIDs {
+		// URLs and descriptions are copied as stored, still sealed when
+		// DB_PASSPHRASE is set.
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO links(name, url, description, logo_url, custom_logo_url, category_id, position, created_at, updated_at,
+			                   target_blank, confirm, og_title, og_description, og_image, visible_from, visible_to, enabled, expires_at)
+			 SELECT name, url, description, logo_url, custom_logo_url, ?, ?, ?, ?,
+			        target_blank, confirm, og_title, og_description, og_image, visible_from, visible_to, enabled, expires_at
+			 FROM links WHERE id = ?`,
+			newID, position, now, now, linkID,
+		)
+		if err != nil {
+			http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+			return
+		}
+		copyID, _ := res.LastInsertId()
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO link_icons(link_id, content_type, data, updated_at)
+			 SELECT ?, content_type, data, ? FROM link_icons WHERE link_id = ?`,
+			copyID, now, linkID,
+		); err != nil {
+			http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to duplicate category", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.created", ID: newID})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusCreated, map[string]any{"id": strconv.FormatInt(newID, 10), "links": len(linkIDs)})
+		return
+	}
+	s.renderDashboard(w, panelID)
+}
+
+// maxBulkCategories caps how many names one bulk create may carry.
+const maxBulkCategories = 100
+
+type bulkCategory struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type bulkCategorySkip struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type bulkCategoryReport struct {
+	Created []bulkCategory     `json:"created"`
+	Skipped []bulkCategorySkip `json:"skipped"`
+}
+
+// parseBulkCategoryNames reads names from a JSON array of strings or from
+// a names form field with one name per line. Names are trimmed, blanks
+// dropped, and repeats kept once.
+func parseBulkCategoryNames(r *http.Request) ([]string, int64, error) {
+	var raw []string
+	var panelID int64
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			return nil, 0, err
+		}
+		panelID = parseInt64OrZero(r.URL.Query().Get("active_panel_id"))
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return nil, 0, err
+		}
+		raw = strings.Split(r.FormValue("names"), "\n")
+		panelID = parseInt64OrZero(r.FormValue("active_panel_id"))
+	}
+	names := make([]string, 0, len(raw))
+	seen := make(map[string]bool, len(raw))
+	for _, name := range raw {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, panelID, nil
+}
+
+// handleBulkCreateCategories adds several categories to the active panel
+// in one transaction. Names that already exist, are too long, or would go
+// past MAX_CATEGORIES are skipped and reported instead of failing the
+// whole request.
+func (s *server) handleBulkCreateCategories(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	names, requestedPanelID, err := parseBulkCategoryNames(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if len(names) == 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, fieldErrors{"names": "required"})
+		return
+	}
+	if len(names) > maxBulkCategories {
+		writeFieldErrors(w, r, http.StatusBadRequest, fieldErrors{"names": fmt.Sprintf("at most %d names at once", maxBulkCategories)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	activePanelID, err := s.resolvePanelID(ctx, requestedPanelID)
+	if err != nil {
+		http.Error(w, "panel not found", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to create categories", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var total, nextPos int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&total); err != nil {
+		http.Error(w, "failed to create categories", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM categories WHERE panel_id = ?`, activePanelID).Scan(&nextPos); err != nil {
+		http.Error(w, "failed to create categories", http.StatusInternalServerError)
+		return
+	}
+	report := bulkCategoryReport{Created: []bulkCategory{}, Skipped: []bulkCategorySkip{}}
+	for _, name := range names {
+		if errs := (categoryInput{Name: name}).validate(); len(errs) > 0 {
+			report.Skipped = append(report.Skipped, bulkCategorySkip{Name: name, Reason: errs["name"]})
+			continue
+		}
+		if s.maxCategories > 0 && total >= s.maxCategories {
+			report.Skipped = append(report.Skipped, bulkCategorySkip{Name: name, Reason: "category limit reached"})
+			continue
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO categories(panel_id, name, position) VALUES(?, ?, ?)`,
+			activePanelID, name, nextPos,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				report.Skipped = append(report.Skipped, bulkCategorySkip{Name: name, Reason: "already exists"})
+				continue
+			}
+			http.Error(w, "failed to create categories", http.StatusInternalServerError)
+			return
+		}
+		id, _ := res.LastInsertId()
+		report.Created = append(report.Created, bulkCategory{ID: id, Name: name})
+		total++
+		nextPos++
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to create categories", http.StatusInternalServerError)
+		return
+	}
+	if len(report.Created) > 0 {
+		s.markChanged(changeEvent{Type: "categories.created"})
+	}
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	notice := fmt.Sprintf("Created %d categories.", len(report.Created))
+	if len(report.Skipped) > 0 {
+		skipped := make([]string, 0, len(report.Skipped))
+		for _, skip := range report.Skipped {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", skip.Name, skip.Reason))
+		}
+		notice += fmt.Sprintf(" Skipped %d: %s", len(report.Skipped), strings.Join(skipped, "; "))
+	}
+	s.renderDashboardNotice(w, activePanelID, notice)
+}
+
+func (s *server) handleCategoryActions(w http.ResponseWriter, r *http.Request) {
+	categoryID := parseInt64OrZero(r.PathValue("id"))
+	if categoryID == 0 {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+	switch r.PathValue("action") {
+	case "delete":
+		s.handleDeleteCategory(w, r, categoryID)
+	case "toggle":
+		s.handleToggleCategory(w, r, categoryID)
+	case "archive":
+		s.handleArchiveCategory(w, r, categoryID)
+	case "update":
+		s.handleUpdateCategory(w, r, categoryID)
+	case "share":
+		s.handleShareCategory(w, r, categoryID)
+	case "unshare":
+		s.handleUnshareCategory(w, r, categoryID)
+	case "duplicate":
+		s.handleDuplicateCategory(w, r, categoryID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *server) handleDeleteCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	reassignTo := parseInt64OrZero(r.FormValue("reassign_to"))
+	if reassignTo == categoryID {
+		http.Error(w, "cannot reassign links to the category being deleted", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if protected, err := s.protectedCategory(ctx, categoryID); err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	} else if protected {
+		http.Error(w, uncategorizedName+" cannot be deleted while AUTO_UNCATEGORIZED is on", http.StatusConflict)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	entry := undoEntry{label: "category"}
+	entry.categories, err = snapshotRowsTx(ctx, tx, "categories", `id = ?`, categoryID)
+	if err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	summary := deleteSummary{Categories: len(entry.categories)}
+	if reassignTo == 0 {
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM links WHERE category_id = ?`, categoryID).Scan(&summary.Links); err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+	}
+	if !s.deleteConfirmed(w, r, summary) {
+		return
+	}
+	var categoryName string
+	if err := tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, categoryID).Scan(&categoryName); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	if reassignTo != 0 {
+		if err := s.checkCategoryMoveTx(ctx, tx, categoryID, reassignTo); err != nil {
+			writeCapacityError(w, err, "failed to delete category")
+			return
+		}
+		if err := moveCategoryLinksTx(ctx, tx, categoryID, reassignTo); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "target category not found", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		entry.links, err = snapshotRowsTx(ctx, tx, "links", `category_id = ?`, categoryID)
+		if err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+		entry.icons, err = snapshotRowsTx(ctx, tx, "link_icons", `link_id IN (SELECT id FROM links WHERE category_id = ?)`, categoryID)
+		if err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+		entry.visits, err = snapshotRowsTx(ctx, tx, "link_visits", `link_id IN (SELECT id FROM links WHERE category_id = ?)`, categoryID)
+		if err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+		entry.favorites, err = snapshotRowsTx(ctx, tx, "favorites", `link_id IN (SELECT id FROM links WHERE category_id = ?)`, categoryID)
+		if err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE category_id = ?`, categoryID); err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, categoryID); err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	if len(entry.categories) > 0 {
+		details := map[string]any{"name": categoryName, "links": summary.Links}
+		if reassignTo != 0 {
+			details["reassigned_to"] = reassignTo
+		}
+		if err := recordAudit(ctx, tx, "delete", "category", categoryID, details); err != nil {
+			http.Error(w, "failed to delete category", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	if len(entry.categories) > 0 {
+		s.undo.push(entry)
+	}
+	s.markChanged(changeEvent{Type: "category.deleted", ID: categoryID})
+	s.renderDashboard(w, activePanelID)
+}
+
+// deleteSummary counts what a delete would remove.
+type deleteSummary struct {
+	Categories int `json:"categories"`
+	Links      int `json:"links"`
+}
+
+// deleteConfirmed guards deletes that would remove more than
+// DELETE_CONFIRM_THRESHOLD categories and links together: unless the
+// request says confirm=true it answers 409 with the summary and returns
+// false. Callers count inside the delete's transaction, before changing
+// anything, so the summary matches what is then removed.
+func (s *server) deleteConfirmed(w http.ResponseWriter, r *http.Request, summary deleteSummary) bool {
+	if s.deleteConfirmThreshold <= 0 || summary.Categories+summary.Links <= s.deleteConfirmThreshold {
+		return true
+	}
+	if r.FormValue("confirm") == "true" {
+		return true
+	}
+	message := fmt.Sprintf("this would delete %d categories and %d links; send confirm=true to go ahead", summary.Categories, summary.Links)
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": message, "would_delete": summary})
+		return false
+	}
+	http.Error(w, message, http.StatusConflict)
+	return false
+}
+
+func (s *server) handleUpdateCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parseCategoryInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if errs := in.validate(); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	res, err := s.execRetry(ctx, `UPDATE categories SET name = ?, description = ? WHERE id = ?`, in.Name, in.Description, categoryID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"name": "category already exists in this panel"})
+			return
+		}
+		http.Error(w, "failed to update category", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.updated", ID: categoryID})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, map[string]string{"id": strconv.FormatInt(categoryID, 10)})
+		return
+	}
+	s.renderDashboard(w, in.ActivePanelID)
+}
+
+// handleToggleCategory stores collapsed=1 or collapsed=0 as an explicit
+// collapse or expand, which also overrides COLLAPSE_THRESHOLD. Without the
+// field it flips the stored state.
+func (s *server) handleToggleCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var res sql.Result
+	var err error
+	switch r.FormValue("collapsed") {
+	case "1":
+		res, err = s.execRetry(ctx, `UPDATE categories SET collapsed = ? WHERE id = ?`, collapseClosed, categoryID)
+	case "0":
+		res, err = s.execRetry(ctx, `UPDATE categories SET collapsed = ? WHERE id = ?`, collapseOpen, categoryID)
+	default:
+		res, err = s.execRetry(ctx,
+			`UPDATE categories SET collapsed = CASE collapsed WHEN ? THEN ? ELSE ? END WHERE id = ?`,
+			collapseClosed, collapseOpen, collapseClosed, categoryID,
+		)
+	}
+	if err != nil {
+		http.Error(w, "failed to toggle category", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.updated", ID: categoryID})
+	s.renderCategoryOrDashboard(w, r, activePanelID, categoryID)
+}
+
+// handleArchiveCategory flips a category between archived and active.
+// Its links are left untouched and reappear with it.
+func (s *server) handleArchiveCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	res, err := s.execRetry(ctx, `UPDATE categories SET archived = 1 - archived WHERE id = ?`, categoryID)
+	if err != nil {
+		http.Error(w, "failed to archive category", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.updated", ID: categoryID})
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleMergeCategories(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	sourceID := parseInt64OrZero(r.FormValue("source_id"))
+	targetID := parseInt64OrZero(r.FormValue("target_id"))
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	if sourceID == 0 || targetID == 0 {
+		http.Error(w, "source and target categories are required", http.StatusBadRequest)
+		return
+	}
+	if sourceID == targetID {
+		http.Error(w, "cannot merge a category into itself", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if protected, err := s.protectedCategory(ctx, sourceID); err != nil {
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	} else if protected {
+		http.Error(w, uncategorizedName+" cannot be merged away while AUTO_UNCATEGORIZED is on", http.StatusConflict)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var sourceName string
+	if err := tx.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, sourceID).Scan(&sourceName); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "source category not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	if err := s.checkCategoryMoveTx(ctx, tx, sourceID, targetID); err != nil {
+		writeCapacityError(w, err, "failed to merge categories")
+		return
+	}
+	if err := moveCategoryLinksTx(ctx, tx, sourceID, targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "target category not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, sourceID); err != nil {
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	if err := recordAudit(ctx, tx, "merge", "category", sourceID, map[string]any{"name": sourceName, "target_id": targetID}); err != nil {
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to merge categories", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.merged", ID: sourceID})
+	s.renderDashboard(w, activePanelID)
+}
+
+// checkCategoryMoveTx applies the per-category cap to moving every link of
+// sourceID into targetID.
+func (s *server) checkCategoryMoveTx(ctx context.Context, tx *sql.Tx, sourceID int64, targetID int64) error {
+	var moving int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM links WHERE category_id = ?`, sourceID).Scan(&moving); err != nil {
+		return err
+	}
+	return s.checkCategoryCapacity(ctx, tx, targetID, moving)
+}
+
+// moveCategoryLinksTx appends every link of the source category to the end
+// of the target category, keeping their relative order. It returns
+// sql.ErrNoRows when the target category does not exist.
+func moveCategoryLinksTx(ctx context.Context, tx *sql.Tx, sourceID int64, targetID int64) error {
+	var exists int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, targetID).Scan(&exists); err != nil {
+		return err
+	}
+	var offset int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM links WHERE category_id = ?`, targetID).Scan(&offset); err != nil {
+		return err
+	}
+	moving, err := linkOrderTx(ctx, tx, sourceID, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE links SET category_id = ?, position = position + ?, updated_at = ? WHERE category_id = ?`,
+		targetID, offset, time.Now().Unix(), sourceID,
+	); err != nil {
+		return err
+	}
+	return clearHotkeyConflictsTx(ctx, tx, moving)
+}
+
+// bulkReplaceChange is one link URL that a bulk replace rewrites. Error is
+// set when the rewritten URL would no longer be a valid link URL.
+type bulkReplaceChange struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	OldURL string `json:"old_url"`
+	NewURL string `json:"new_url"`
+	Error  string `json:"error,omitempty"`
+}
+
+type bulkReplaceReport struct {
+	Changed int                 `json:"changed"`
+	DryRun  bool                `json:"dry_run"`
+	Links   []bulkReplaceChange `json:"links"`
+}
+
+// handleBulkReplaceURLs substitutes replace for every occurrence of find in
+// link URLs, for moving self-hosted services to a new hostname. All links
+// are rewritten in one transaction; if any result is not a valid URL
+// nothing is written and the offending links are reported with a 422.
+// With dry_run=1 it only reports what would change.
+func (s *server) handleBulkReplaceURLs(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	find := r.FormValue("find")
+	replace := r.FormValue("replace")
+	dryRun := r.FormValue("dry_run") == "1"
+	if find == "" {
+		writeFieldErrors(w, r, http.StatusBadRequest, fieldErrors{"find": "required"})
+		return
+	}
+	if find == replace {
+		writeFieldErrors(w, r, http.StatusBadRequest, fieldErrors{"replace": "must differ from find"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	// Every URL is read and matched in Go because encrypted URLs cannot
+	// be searched in SQL.
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, url FROM links ORDER BY id ASC`)
+	if err != nil {
+		http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+		return
+	}
+	report := bulkReplaceReport{DryRun: dryRun, Links: []bulkReplaceChange{}}
+	invalid := false
+	for rows.Next() {
+		var change bulkReplaceChange
+		if err := rows.Scan(&change.ID, &change.Name, &change.OldURL); err != nil {
+			rows.Close()
+			http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&change.OldURL); err != nil {
+			rows.Close()
+			http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+			return
+		}
+		if !strings.Contains(change.OldURL, find) {
+			continue
+		}
+		change.NewURL = strings.ReplaceAll(change.OldURL, find, replace)
+		if errs := (linkInput{Name: change.Name, URL: change.NewURL}).validateFields(); errs["url"] != "" {
+			change.Error = "url " + errs["url"]
+			invalid = true
+		}
+		report.Links = append(report.Links, change)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+		return
+	}
+	report.Changed = len(report.Links)
+	if invalid {
+		report.Changed = 0
+		writeJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun || report.Changed == 0 {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, change := range report.Links {
+		sealedURL, err := s.cipher.seal(change.NewURL)
+		if err != nil {
+			http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE links
+			 SET url = ?, logo_url = CASE WHEN custom_logo_url = '' THEN ? ELSE logo_url END, updated_at = ?
+			 WHERE id = ?`,
+			sealedURL, s.derivedLogoURL(change.NewURL), now, change.ID,
+		); err != nil {
+			http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := recordAudit(ctx, tx, "bulk_replace", "link", 0, map[string]any{"changed": report.Changed}); err != nil {
+		http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to replace urls", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "links.replaced"})
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *server) handleCreateLink(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parseLinkInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if in.CategoryID != 0 && s.autoUncategorized {
+		// A category deleted while the form was open sends the link to
+		// the fallback instead of failing.
+		var exists int64
+		err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, in.CategoryID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			in.CategoryID = 0
+		} else if err != nil {
+			http.Error(w, "failed to create link", http.StatusInternalServerError)
+			return
+		}
+	}
+	var uncategorizedPanelID int64
+	if in.CategoryID == 0 {
+		if in.CategoryID, uncategorizedPanelID, err = s.defaultCategoryID(ctx, in.ActivePanelID); err != nil {
+			http.Error(w, "failed to create link", http.StatusInternalServerError)
+			return
+		}
+	}
+	errs := s.validateLinkInput(ctx, in)
+	// A missing Uncategorized is only created below, for a valid link.
+	createCategory := in.CategoryID == 0
+	if createCategory {
+		delete(errs, "category_id")
+	}
+	if len(errs) > 0 {
+		if isJSONRequest(r) {
+			writeFieldErrors(w, r, http.StatusBadRequest, errs)
+			return
+		}
+		s.renderLinkFormErrors(w, r, in, errs)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to create link", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if createCategory {
+		if in.CategoryID, err = s.ensureCategoryTx(ctx, tx, uncategorizedPanelID, uncategorizedName); err != nil {
+			writeCapacityError(w, err, "failed to create link")
+			return
+		}
+	}
+	if err := s.checkCategoryCapacity(ctx, tx, in.CategoryID, 1); err != nil {
+		writeCapacityError(w, err, "failed to create link")
+		return
+	}
+	if taken, err := hotkeyTaken(ctx, tx, in.Hotkey, in.CategoryID, 0); err != nil {
+		http.Error(w, "failed to create link", http.StatusInternalServerError)
+		return
+	} else if taken {
+		writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"hotkey": "already used by another link on this panel"})
+		return
+	}
+	newID, err := s.insertLink(ctx, tx, in)
+	if err != nil {
+		http.Error(w, "failed to create link", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to create link", http.StatusInternalServerError)
+		return
+	}
+	if createCategory {
+		s.markChanged(changeEvent{Type: "category.created", ID: in.CategoryID})
+	}
+	s.markChanged(changeEvent{Type: "link.created", ID: newID})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusCreated, map[string]string{"id": strconv.FormatInt(newID, 10)})
+		return
+	}
+	s.renderDashboard(w, in.ActivePanelID)
+}
+
+// renderLinkFormErrors sends the create form back with the submitted
+// values and inline errors. htmx is told to swap it over the form itself
+// rather than the dashboard the form normally targets.
+func (s *server) renderLinkFormErrors(w http.ResponseWriter, r *http.Request, in linkInput, errs fieldErrors) {
+	data, err := s.getDashboardData(r.Context(), in.ActivePanelID, defaultLinkSort)
+	if err != nil {
+		http.Error(w, errs.Error(), http.StatusBadRequest)
+		return
+	}
+	view := data.LinkForm()
+	view.Errors = errs
+	view.Values = linkFormValues{
+		Name:        in.Name,
+		URL:         in.URL,
+		Description: in.Description,
+		TargetBlank: in.TargetBlank == nil || *in.TargetBlank,
+		Confirm:     in.Confirm != nil && *in.Confirm,
+		Hotkey:      in.Hotkey,
+		ExpiresAt:   in.ExpiresAt,
+	}
+	if in.CategoryID > 0 {
+		view.Values.CategoryID = strconv.FormatInt(in.CategoryID, 10)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("HX-Retarget", "#add-link-form")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := s.templates.ExecuteTemplate(w, "link-form", view); err != nil {
+		log.Printf("render link form: %v", err)
+	}
+}
+
+// dbtx is satisfied by both *sql.DB and *sql.Tx so write helpers can run
+// standalone or as part of a larger transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// categoryFullError reports that a category has reached
+// MAX_LINKS_PER_CATEGORY.
+type categoryFullError struct {
+	count int
+	limit int
+}
+
+func (e categoryFullError) Error() string {
+	return fmt.Sprintf("category is full (%d of %d links)", e.count, e.limit)
+}
+
+// checkCategoryCapacity fails with categoryFullError when adding more
+// links to categoryID would exceed the configured cap. Categories already
+// over a lowered cap can still be reordered, just not grown.
+func (s *server) checkCategoryCapacity(ctx context.Context, db dbtx, categoryID int64, adding int) error {
+	if s.maxLinksPerCategory <= 0 || adding <= 0 {
+		return nil
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM links WHERE category_id = ?`, categoryID).Scan(&count); err != nil {
+		return err
+	}
+	if count+adding > s.maxLinksPerCategory {
+		return categoryFullError{count: count, limit: s.maxLinksPerCategory}
+	}
+	return nil
+}
+
+// categoryLimitError reports that the dashboard has reached
+// MAX_CATEGORIES.
+type categoryLimitError struct {
+	count int
+	limit int
+}
+
+func (e categoryLimitError) Error() string {
+	return fmt.Sprintf("category limit reached (%d of %d categories)", e.count, e.limit)
+}
+
+// checkCategoryLimit fails with categoryLimitError when creating adding
+// more categories would exceed MAX_CATEGORIES.
+func (s *server) checkCategoryLimit(ctx context.Context, db dbtx, adding int) error {
+	if s.maxCategories <= 0 || adding <= 0 {
+		return nil
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&count); err != nil {
+		return err
+	}
+	if count+adding > s.maxCategories {
+		return categoryLimitError{count: count, limit: s.maxCategories}
+	}
+	return nil
+}
+
+// writeCapacityError answers 409 for a full category or a full dashboard
+// and falls back to a 500 with failure for anything else.
+func writeCapacityError(w http.ResponseWriter, err error, failure string) {
+	var full categoryFullError
+	var limit categoryLimitError
+	if errors.As(err, &full) || errors.As(err, &limit) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, failure, http.StatusInternalServerError)
+}
+
+// insertLink appends a validated link to the end of its category.
+func (s *server) insertLink(ctx context.Context, db dbtx, in linkInput) (int64, error) {
+	var nextPos int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM links WHERE category_id = ?`, in.CategoryID).Scan(&nextPos); err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	logo := s.derivedLogoURL(in.URL)
+	if in.CustomLogoURL != "" {
+		logo = in.CustomLogoURL
+	}
+	sealedURL, sealedDescription, err := s.cipher.sealLink(in.URL, in.Description)
+	if err != nil {
+		return 0, err
+	}
+	expiresAt, _ := s.parseExpiry(in.ExpiresAt)
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO links(name, url, description, logo_url, custom_logo_url, category_id, position, created_at, updated_at, target_blank,
+		                   visible_from, visible_to, confirm, hotkey, expires_at, enabled)
+		 VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		in.Name, sealedURL, sealedDescription, logo, in.CustomLogoURL, in.CategoryID, nextPos, now, now, in.TargetBlank == nil || *in.TargetBlank,
+		in.VisibleFrom, in.VisibleTo, in.Confirm != nil && *in.Confirm, in.Hotkey, expiresAt, !in.Disabled,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *server) handleLinkActions(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.Error(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	switch r.PathValue("action") {
+	case "delete":
+		s.handleDeleteLink(w, r, id)
+	case "update":
+		s.handleUpdateLink(w, r, id)
+	case "enrich":
+		s.handleEnrichLink(w, r, id)
+	case "duplicate":
+		s.handleDuplicateLink(w, r, id)
+	case "check":
+		s.handleCheckLink(w, r, id)
+	case "top":
+		s.handleMoveLinkToTop(w, r, id)
+	case "place":
+		s.handlePlaceLink(w, r, id)
+	case "icon":
+		s.handleUploadLinkIcon(w, r, id)
+	case "icon-delete":
+		s.handleDeleteLinkIcon(w, r, id)
+	case "toggle":
+		s.handleToggleLink(w, r, id)
+	case "favorite":
+		s.handleAddFavorite(w, r, id)
+	case "unfavorite":
+		s.handleRemoveFavorite(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleToggleLink stores enabled=1 or enabled=0; without the field it
+// flips the stored state. Disabled links keep their place on the
+// dashboard but /go/{id} refuses them.
+func (s *server) handleToggleLink(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var res sql.Result
+	var err error
+	switch r.FormValue("enabled") {
+	case "1":
+		res, err = s.execRetry(ctx, `UPDATE links SET enabled = 1 WHERE id = ?`, id)
+	case "0":
+		res, err = s.execRetry(ctx, `UPDATE links SET enabled = 0 WHERE id = ?`, id)
+	default:
+		res, err = s.execRetry(ctx, `UPDATE links SET enabled = 1 - enabled WHERE id = ?`, id)
+	}
+	if err != nil {
+		http.Error(w, "failed to toggle link", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "link not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	if isJSONRequest(r) {
+		var enabled bool
+		if err := s.db.QueryRowContext(ctx, `SELECT enabled FROM links WHERE id = ?`, id).Scan(&enabled); err != nil {
+			http.Error(w, "failed to toggle link", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"id": strconv.FormatInt(id, 10), "enabled": enabled})
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleDeleteLink(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	entry := undoEntry{label: "link"}
+	entry.links, err = snapshotRowsTx(ctx, tx, "links", `id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	entry.icons, err = snapshotRowsTx(ctx, tx, "link_icons", `link_id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	entry.visits, err = snapshotRowsTx(ctx, tx, "link_visits", `link_id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	entry.favorites, err = snapshotRowsTx(ctx, tx, "favorites", `link_id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	var linkName string
+	var categoryID int64
+	if err := tx.QueryRowContext(ctx, `SELECT name, category_id FROM links WHERE id = ?`, id).Scan(&linkName, &categoryID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE id = ?`, id); err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	if len(entry.links) > 0 {
+		if err := recordAudit(ctx, tx, "delete", "link", id, map[string]any{"name": linkName, "category_id": categoryID}); err != nil {
+			http.Error(w, "failed to delete link", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to delete link", http.StatusInternalServerError)
+		return
+	}
+	if len(entry.links) > 0 {
+		s.undo.push(entry)
+	}
+	s.markChanged(changeEvent{Type: "link.deleted", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	entry, ok := s.undo.pop(time.Now())
+	if !ok {
+		http.Error(w, "nothing to undo", http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.undo.push(entry)
+		http.Error(w, "failed to undo", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if err := entry.restoreTx(ctx, tx); err != nil {
+		s.undo.push(entry)
+		if isConstraintViolation(err) {
+			http.Error(w, fmt.Sprintf("cannot restore deleted %s: it conflicts with the current data", entry.label), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to undo", http.StatusInternalServerError)
+		return
+	}
+	details := map[string]any{"categories": len(entry.categories), "links": len(entry.links)}
+	if err := recordAudit(ctx, tx, "undo", entry.label, 0, details); err != nil {
+		s.undo.push(entry)
+		http.Error(w, "failed to undo", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		s.undo.push(entry)
+		http.Error(w, "failed to undo", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "dashboard.restored"})
+	s.renderDashboard(w, activePanelID)
+}
+
+const (
+	maxUndoEntries = 20
+	undoWindow     = 10 * time.Minute
+)
+
+// undoLog remembers the most recent destructive actions so they can be
+// reverted. It is bounded to maxUndoEntries and safe for concurrent use.
+type undoLog struct {
+	mu      sync.Mutex
+	entries []undoEntry
+}
+
+type undoEntry struct {
+	at         time.Time
+	label      string
+	categories []rowSnapshot
+	links      []rowSnapshot
+	icons      []rowSnapshot
+	visits     []rowSnapshot
+	favorites  []rowSnapshot
+}
+
+func (u *undoLog) push(entry undoEntry) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if entry.at.IsZero() {
+		entry.at = time.Now()
+	}
+	u.entries = append(u.entries, entry)
+	if len(u.entries) > maxUndoEntries {
+		u.entries = u.entries[len(u.entries)-maxUndoEntries:]
+	}
+}
+
+// restoreTx re-inserts every snapshot of the entry, parents before the
+// rows that reference them.
+func (e undoEntry) restoreTx(ctx context.Context, tx *sql.Tx) error {
+	tables := []struct {
+		name string
+		rows []rowSnapshot
+	}{
+		{"categories", e.categories},
+		{"links", e.links},
+		{"link_icons", e.icons},
+		{"link_visits", e.visits},
+		{"favorites", e.favorites},
+	}
+	for _, table := range tables {
+		for _, row := range table.rows {
+			if err := row.restoreTx(ctx, tx, table.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pop removes and returns the newest entry if it is still within
+// undoWindow. Entries are chronological, so an expired newest entry means
+// everything is expired and the log is cleared.
+func (u *undoLog) pop(now time.Time) (undoEntry, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.entries) == 0 {
+		return undoEntry{}, false
+	}
+	last := u.entries[len(u.entries)-1]
+	if now.Sub(last.at) > undoWindow {
+		u.entries = nil
+		return undoEntry{}, false
+	}
+	u.entries = u.entries[:len(u.entries)-1]
+	return last, true
+}
+
+// rowSnapshot is a full copy of a table row, column for column, so it can
+// be re-inserted verbatim regardless of which columns the schema has.
+type rowSnapshot struct {
+	columns []string
+	values  []any
+}
+
+func snapshotRowsTx(ctx context.Context, tx *sql.Tx, table string, where string, args ...any) ([]rowSnapshot, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE %s", table, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []rowSnapshot
+	for rows.Next() {
+		values := make([]any, len(columns))
+		targets := make([]any, len(columns))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, rowSnapshot{columns: columns, values: values})
+	}
+	return snapshots, rows.Err()
+}
+
+func (row rowSnapshot) restoreTx(ctx context.Context, tx *sql.Tx, table string) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(row.columns)), ", ")
+	_, err := tx.ExecContext(ctx,
+		fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", table, strings.Join(row.columns, ", "), placeholders),
+		row.values...,
+	)
+	return err
+}
+
+func (s *server) handleUpdateLink(w http.ResponseWriter, r *http.Request, id int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parseLinkInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if errs := s.validateLinkInput(ctx, in); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+	var currentCategoryID int64
+	if err := s.db.QueryRowContext(ctx, `SELECT category_id FROM links WHERE id = ?`, id).Scan(&currentCategoryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to update link", http.StatusInternalServerError)
+		return
+	}
+	if currentCategoryID != in.CategoryID {
+		if err := s.checkCategoryCapacity(ctx, s.db, in.CategoryID, 1); err != nil {
+			writeCapacityError(w, err, "failed to update link")
+			return
+		}
+	}
+	if taken, err := hotkeyTaken(ctx, s.db, in.Hotkey, in.CategoryID, id); err != nil {
+		http.Error(w, "failed to update link", http.StatusInternalServerError)
+		return
+	} else if taken {
+		writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"hotkey": "already used by another link on this panel"})
+		return
+	}
+	logo := s.derivedLogoURL(in.URL)
+	if in.CustomLogoURL != "" {
+		logo = in.CustomLogoURL
+	}
+
+	sealedURL, sealedDescription, err := s.cipher.sealLink(in.URL, in.Description)
+	if err != nil {
+		http.Error(w, "failed to update link", http.StatusInternalServerError)
+		return
+	}
+	expiresAt, _ := s.parseExpiry(in.ExpiresAt)
+	now := time.Now().Unix()
+	_, err = s.execRetry(ctx,
+		`UPDATE links
+		 SET name = ?, url = ?, description = ?, logo_url = ?, custom_logo_url = ?, category_id = ?, updated_at = ?,
+		     target_blank = COALESCE(?, target_blank), confirm = COALESCE(?, confirm), visible_from = ?, visible_to = ?, hotkey = ?,
+		     expires_at = ?
+		 WHERE id = ?`,
+		in.Name, sealedURL, sealedDescription, logo, in.CustomLogoURL, in.CategoryID, now, in.TargetBlank, in.Confirm, in.VisibleFrom, in.VisibleTo, in.Hotkey,
+		expiresAt, id,
+	)
+	if err != nil {
+		http.Error(w, "failed to update link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, map[string]string{"id": strconv.FormatInt(id, 10)})
+		return
+	}
+	s.renderDashboard(w, in.ActivePanelID)
+}
+
+func (s *server) handleDuplicateLink(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var in linkInput
+	var targetBlank, confirm, enabled bool
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name, url, description, custom_logo_url, category_id, target_blank, confirm, visible_from, visible_to, enabled, expires_at
+		 FROM links WHERE id = ?`, id,
+	).Scan(&in.Name, &in.URL, &in.Description, &in.CustomLogoURL, &in.CategoryID, &targetBlank, &confirm, &in.VisibleFrom, &in.VisibleTo, &enabled, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to duplicate link", http.StatusInternalServerError)
+		return
+	}
+	if err := s.cipher.openAll(&in.URL, &in.Description); err != nil {
+		http.Error(w, "failed to duplicate link", http.StatusInternalServerError)
+		return
+	}
+	in.TargetBlank = &targetBlank
+	in.Confirm = &confirm
+	in.Disabled = !enabled
+	if categoryID := parseInt64OrZero(r.FormValue("category_id")); categoryID != 0 {
+		in.CategoryID = categoryID
+	}
+	if errs := s.validateLinkInput(ctx, in); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+	// The expiry is copied after validation, which would refuse the copy
+	// of a link that has already expired.
+	if expiresAt != 0 {
+		in.ExpiresAt = time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	if err := s.checkCategoryCapacity(ctx, s.db, in.CategoryID, 1); err != nil {
+		writeCapacityError(w, err, "failed to duplicate link")
+		return
+	}
+	newID, err := s.insertLink(ctx, s.db, in)
+	if err != nil {
+		http.Error(w, "failed to duplicate link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.created", ID: newID})
+	s.renderDashboard(w, activePanelID)
+}
+
+type linkCheckResult struct {
+	ID          string    `json:"id"`
+	LastStatus  int       `json:"last_status"`
+	LastChecked time.Time `json:"last_checked"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// handleCheckLink requests the link's URL right away and stores the
+// resulting status. An unreachable site is recorded as status 0 rather
+// than failing the request.
+func (s *server) handleCheckLink(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var target string
+	if err := s.db.QueryRowContext(ctx, `SELECT url FROM links WHERE id = ?`, id).Scan(&target); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to check link", http.StatusInternalServerError)
+		return
+	}
+	if err := s.cipher.openAll(&target); err != nil {
+		http.Error(w, "failed to check link", http.StatusInternalServerError)
+		return
+	}
+	result := linkCheckResult{ID: strconv.FormatInt(id, 10), LastChecked: time.Now().UTC().Truncate(time.Second)}
+	status, err := checkLinkStatus(ctx, target)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.LastStatus = status
+	if _, err := s.execRetry(ctx,
+		`UPDATE links SET last_status = ?, last_checked = ? WHERE id = ?`,
+		result.LastStatus, result.LastChecked.Unix(), id,
+	); err != nil {
+		http.Error(w, "failed to check link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.checked", ID: id})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+// checkLinkStatus returns the final HTTP status for target, trying HEAD
+// first and falling back to GET for servers that refuse HEAD.
+func checkLinkStatus(ctx context.Context, target string) (int, error) {
+	status, err := requestStatus(ctx, http.MethodHead, target)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = requestStatus(ctx, http.MethodGet, target)
+	}
+	return status, err
+}
+
+func requestStatus(ctx context.Context, method string, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxFetchBytes))
+	return resp.StatusCode, nil
+}
+
+type urlCheckResult struct {
+	URL       string `json:"url"`
+	FinalURL  string `json:"final_url,omitempty"`
+	Status    int    `json:"status"`
+	Reachable bool   `json:"reachable"`
+	Title     string `json:"title,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleCheckURL tells whether a URL answers before it is saved: it
+// tries HEAD, falling back to GET, and reports the final status after
+// redirects along with the page title of HTML pages. Unreachable sites
+// are reported in the result; URLs leading to a private address answer
+// 403.
+func (s *server) handleCheckURL(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimSpace(r.URL.Query().Get("url"))
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		http.Error(w, "url must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	result := urlCheckResult{URL: target}
+	resp, err := s.checkURLRequest(ctx, http.MethodHead, target)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = s.checkURLRequest(ctx, http.MethodGet, target)
+	}
+	if errors.Is(err, errBlockedAddress) {
+		http.Error(w, "url leads to a private or loopback address, see ALLOW_PRIVATE_FETCH", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		result.Error = err.Error()
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	defer resp.Body.Close()
+	result.Status = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	result.Reachable = resp.StatusCode >= 200 && resp.StatusCode < 400
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); result.Reachable && mediaType == "text/html" {
+		body := resp.Body
+		if resp.Request.Method == http.MethodHead {
+			page, err := s.checkURLRequest(ctx, http.MethodGet, result.FinalURL)
+			if err == nil {
+				defer page.Body.Close()
+				body = page.Body
+			}
+		}
+		og := parseOpenGraph(io.LimitReader(body, maxFetchBytes))
+		result.Title = og.PageTitle
+		if og.Title != "" {
+			result.Title = og.Title
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *server) checkURLRequest(ctx context.Context, method string, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html, */*")
+	return fetchClient.Do(req)
+}
+
+// handleMoveLinkToTop puts a link first in its category by giving it a
+// position one below the lowest of its siblings. Positions may go
+// negative; only their order matters. The read and write share a
+// transaction so a concurrent move cannot land on the same position.
+func (s *server) handleMoveLinkToTop(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to move link", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var categoryID int64
+	if err := tx.QueryRowContext(ctx, `SELECT category_id FROM links WHERE id = ?`, id).Scan(&categoryID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to move link", http.StatusInternalServerError)
+		return
+	}
+	var lowest sql.NullInt64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT MIN(position) FROM links WHERE category_id = ? AND id <> ?`, categoryID, id,
+	).Scan(&lowest); err != nil {
+		http.Error(w, "failed to move link", http.StatusInternalServerError)
+		return
+	}
+	if lowest.Valid {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE links SET position = ?, updated_at = ? WHERE id = ? AND position >= ?`,
+			lowest.Int64-1, time.Now().Unix(), id, lowest.Int64,
+		); err != nil {
+			http.Error(w, "failed to move link", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to move link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	s.renderCategoryOrDashboard(w, r, activePanelID, categoryID)
+}
+
+// handlePlaceLink moves a link into category_id at a given spot: before
+// the link before_id, else at the 0-based position, else at the end. Both
+// the source and target categories are renumbered in the same
+// transaction, so their positions stay dense.
+func (s *server) handlePlaceLink(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	targetID := parseInt64OrZero(r.FormValue("category_id"))
+	if targetID == 0 {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+	beforeID := parseInt64OrZero(r.FormValue("before_id"))
+	position := -1
+	if raw := strings.TrimSpace(r.FormValue("position")); raw != "" && beforeID == 0 {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "position must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		position = n
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to place link", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var sourceID int64
+	if err := tx.QueryRowContext(ctx, `SELECT category_id FROM links WHERE id = ?`, id).Scan(&sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to place link", http.StatusInternalServerError)
+		return
+	}
+	var exists int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, targetID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to place link", http.StatusInternalServerError)
+		return
+	}
+	if sourceID != targetID {
+		if err := s.checkCategoryCapacity(ctx, tx, targetID, 1); err != nil {
+			writeCapacityError(w, err, "failed to place link")
+			return
+		}
+	}
+
+	order, err := linkOrderTx(ctx, tx, targetID, id)
+	if err != nil {
+		http.Error(w, "failed to place link", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case beforeID != 0:
+		position = slices.Index(order, beforeID)
+		if position < 0 {
+			http.Error(w, "before_id is not another link in the category", http.StatusBadRequest)
+			return
+		}
+	case position < 0 || position > len(order):
+		position = len(order)
+	}
+	order = slices.Insert(order, position, id)
+
+	now := time.Now().Unix()
+	for idx, linkID := range order {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE links SET category_id = ?, position = ?, updated_at = CASE WHEN id = ? THEN ? ELSE updated_at END WHERE id = ?`,
+			targetID, idx, id, now, linkID,
+		); err != nil {
+			http.Error(w, "failed to place link", http.StatusInternalServerError)
+			return
+		}
+	}
+	if sourceID != targetID {
+		if err := clearHotkeyConflictsTx(ctx, tx, []int64{id}); err != nil {
+			http.Error(w, "failed to place link", http.StatusInternalServerError)
+			return
+		}
+		rest, err := linkOrderTx(ctx, tx, sourceID, 0)
+		if err != nil {
+			http.Error(w, "failed to place link", http.StatusInternalServerError)
+			return
+		}
+		for idx, linkID := range rest {
+			if _, err := tx.ExecContext(ctx, `UPDATE links SET position = ? WHERE id = ?`, idx, linkID); err != nil {
+				http.Error(w, "failed to place link", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to place link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+// linkOrderTx returns the ids of a category's links in display order,
+// leaving out skipID.
+func linkOrderTx(ctx context.Context, tx *sql.Tx, categoryID int64, skipID int64) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM links WHERE category_id = ? AND id <> ? ORDER BY position ASC, id ASC`, categoryID, skipID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// maxFavorites caps the favorites bar.
+const maxFavorites = 10
+
+// handleAddFavorite appends the link to the end of the favorites bar.
+// Adding a link that is already there changes nothing.
+func (s *server) handleAddFavorite(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to add favorite", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var exists int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM links WHERE id = ?`, id).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to add favorite", http.StatusInternalServerError)
+		return
+	}
+	var count, nextPos int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(MAX(position), -1) + 1 FROM favorites WHERE link_id <> ?`, id,
+	).Scan(&count, &nextPos); err != nil {
+		http.Error(w, "failed to add favorite", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxFavorites {
+		http.Error(w, fmt.Sprintf("favorites bar is full (%d links)", maxFavorites), http.StatusConflict)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO favorites(link_id, position) VALUES(?, ?)`, id, nextPos); err != nil {
+		http.Error(w, "failed to add favorite", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to add favorite", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "favorite.added", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleRemoveFavorite(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	res, err := s.execRetry(ctx, `DELETE FROM favorites WHERE link_id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to remove favorite", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		s.recordAuditLogged(ctx, "unfavorite", "link", id, nil)
+	}
+	s.markChanged(changeEvent{Type: "favorite.removed", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+// handleReorderFavorites stores the favorites bar order from ordered_ids,
+// a comma-separated list of link ids. Ids not on the bar are ignored.
+func (s *server) handleReorderFavorites(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	ordered := parseIDList(r.FormValue("ordered_ids"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to reorder favorites", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for idx, id := range ordered {
+		if _, err := tx.ExecContext(ctx, `UPDATE favorites SET position = ? WHERE link_id = ?`, idx, id); err != nil {
+			http.Error(w, "failed to reorder favorites", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to reorder favorites", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "favorites.reordered"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadFavoritesBar returns the favorites in bar order with the fields
+// the strip shows.
+func (s *server) loadFavoritesBar(ctx context.Context) ([]dashboardLink, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT l.id, l.name, l.url, l.logo_url, l.category_id, l.target_blank, l.confirm,
+		        COALESCE(i.updated_at, 0), l.visible_from, l.visible_to, l.expires_at, l.enabled = 0
+		 FROM favorites f
+		 JOIN links l ON l.id = f.link_id
+		 LEFT JOIN link_icons i ON i.link_id = l.id
+		 ORDER BY f.position ASC, f.link_id ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]dashboardLink, 0, maxFavorites)
+	for rows.Next() {
+		var id, categoryID, iconVersion, expiresAt int64
+		var name, url, logo, visibleFrom, visibleTo string
+		var targetBlank, confirm, disabled bool
+		if err := rows.Scan(&id, &name, &url, &logo, &categoryID, &targetBlank, &confirm, &iconVersion, &visibleFrom, &visibleTo, &expiresAt, &disabled); err != nil {
+			return nil, err
+		}
+		if err := s.cipher.openAll(&url); err != nil {
+			return nil, err
+		}
+		items = append(items, dashboardLink{
+			ID:          strconv.FormatInt(id, 10),
+			CategoryID:  strconv.FormatInt(categoryID, 10),
+			Name:        name,
+			URL:         url,
+			LogoURL:     logo,
+			IconDataURI: monogramDataURI(name, url),
+			IconVersion: iconVersion,
+			TargetBlank: targetBlank,
+			Confirm:     confirm,
+			Favorite:    true,
+			VisibleFrom: visibleFrom,
+			VisibleTo:   visibleTo,
+			ExpiresAt:   s.localTime(expiresAt),
+			Disabled:    disabled,
+		})
+	}
+	return items, rows.Err()
+}
+
+// handleUploadLinkIcon stores a custom icon for a link from the "icon"
+// file field. Only PNG and JPEG are accepted, judged by sniffing the bytes
+// rather than trusting the declared type.
+func (s *server) handleUploadLinkIcon(w http.ResponseWriter, r *http.Request, id int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxIconBytes+maxFormBytes)
+	if err := r.ParseMultipartForm(maxIconBytes); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	file, _, err := r.FormFile("icon")
+	if err != nil {
+		http.Error(w, "icon: required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxIconBytes+1))
+	if err != nil {
+		http.Error(w, "icon: failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxIconBytes {
+		http.Error(w, fmt.Sprintf("icon must be at most %d bytes", maxIconBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		http.Error(w, "icon must be a PNG or JPEG image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	result, err := s.execRetry(ctx,
+		`INSERT INTO link_icons(link_id, content_type, data, updated_at)
+		 SELECT id, ?, ?, ? FROM links WHERE id = ?
+		 ON CONFLICT(link_id) DO UPDATE SET content_type = excluded.content_type, data = excluded.data, updated_at = excluded.updated_at`,
+		contentType, data, time.Now().UnixNano(), id,
+	)
+	if err != nil {
+		http.Error(w, "failed to save icon", http.StatusInternalServerError)
+		return
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		http.Error(w, "link not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleDeleteLinkIcon(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	res, err := s.execRetry(ctx, `DELETE FROM link_icons WHERE link_id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete icon", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		s.recordAuditLogged(ctx, "delete_icon", "link", id, nil)
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+// handleLinkIcon serves a link's uploaded icon. The URL carries the upload
+// time, so the response can be cached for a long time.
+func (s *server) handleLinkIcon(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var contentType string
+	var data []byte
+	var updatedAt int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT content_type, data, updated_at FROM link_icons WHERE link_id = ?`, id,
+	).Scan(&contentType, &data, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load icon", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", time.Unix(0, updatedAt), bytes.NewReader(data))
+}
+
+func (s *server) handleEnrichLink(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var target string
+	if err := s.db.QueryRowContext(ctx, `SELECT url FROM links WHERE id = ?`, id).Scan(&target); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to enrich link", http.StatusInternalServerError)
+		return
+	}
+	if err := s.cipher.openAll(&target); err != nil {
+		http.Error(w, "failed to enrich link", http.StatusInternalServerError)
+		return
+	}
+	og, err := fetchOpenGraph(ctx, target)
+	if err != nil {
+		log.Printf("enrich link %d: %v", id, err)
+		http.Error(w, "failed to fetch link metadata", http.StatusBadGateway)
+		return
+	}
+	if _, err := s.execRetry(ctx,
+		`UPDATE links SET og_title = ?, og_description = ?, og_image = ?, updated_at = ? WHERE id = ?`,
+		og.Title, og.Description, og.Image, time.Now().Unix(), id,
+	); err != nil {
+		http.Error(w, "failed to enrich link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.updated", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) handleReorderCategories(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	panelID := parseInt64OrZero(r.FormValue("panel_id"))
+	ordered := parseIDList(r.FormValue("ordered_ids"))
+	if panelID == 0 {
+		http.Error(w, "invalid panel id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for idx, id := range ordered {
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET position = ? WHERE id = ? AND panel_id = ?`, idx, id, panelID); err != nil {
+			http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "categories.reordered"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetCategoryOrder rewrites every category position in a panel from
+// a complete ordering. Unlike handleReorderCategories it refuses partial
+// or stale orderings instead of applying what it can.
+func (s *server) handleSetCategoryOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	ordered := make([]int64, 0, len(r.Form["category_id"]))
+	for _, raw := range r.Form["category_id"] {
+		id := parseInt64OrZero(raw)
+		if id == 0 {
+			http.Error(w, "invalid category id", http.StatusBadRequest)
+			return
+		}
+		ordered = append(ordered, id)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	panelID, err := s.resolvePanelID(ctx, parseInt64OrZero(r.FormValue("panel_id")))
+	if err != nil {
+		http.Error(w, "panel not found", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM categories WHERE panel_id = ?`, panelID)
+	if err != nil {
+		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+		return
+	}
+	existing := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+			return
+		}
+		existing[id] = false
+	}
+	rows.Close()
+
+	for _, id := range ordered {
+		seen, ok := existing[id]
+		if !ok || seen {
+			http.Error(w, "category ids must list every category in the panel exactly once", http.StatusBadRequest)
+			return
+		}
+		existing[id] = true
+	}
+	if len(ordered) != len(existing) {
+		http.Error(w, "category ids must list every category in the panel exactly once", http.StatusBadRequest)
+		return
+	}
+
+	for idx, id := range ordered {
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET position = ? WHERE id = ?`, idx, id); err != nil {
+			http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "categories.reordered"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleReorderLinks(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	categoryID := parseInt64OrZero(r.FormValue("category_id"))
+	ordered := parseIDList(r.FormValue("ordered_ids"))
+	if categoryID == 0 {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	resident, err := linkOrderTx(ctx, tx, categoryID, 0)
+	if err != nil {
+		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+		return
+	}
+	before := len(resident)
+	var moved []int64
+	for _, id := range ordered {
+		if !slices.Contains(resident, id) {
+			moved = append(moved, id)
+		}
+	}
+	now := time.Now().Unix()
+	for idx, id := range ordered {
+		if _, err := tx.ExecContext(ctx, `UPDATE links SET category_id = ?, position = ?, updated_at = ? WHERE id = ?`, categoryID, idx, now, id); err != nil {
+			http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Links dragged in from other categories count against the cap;
+	// reordering within the category never does.
+	var after int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM links WHERE category_id = ?`, categoryID).Scan(&after); err != nil {
+		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+		return
+	}
+	if s.maxLinksPerCategory > 0 && after > before && after > s.maxLinksPerCategory {
+		writeCapacityError(w, categoryFullError{count: before, limit: s.maxLinksPerCategory}, "failed to reorder links")
+		return
+	}
+	if err := clearHotkeyConflictsTx(ctx, tx, moved); err != nil {
+		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "links.reordered", ID: categoryID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// presetPlaceholder is replaced with the value supplied when a preset is
+// applied, in every field of every link template.
+const presetPlaceholder = "{name}"
+
+type presetLink struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type presetItem struct {
+	ID    int64        `json:"id"`
+	Name  string       `json:"name"`
+	Links []presetLink `json:"links"`
+}
+
+type presetInput struct {
+	Name  string       `json:"name"`
+	Links []presetLink `json:"links"`
+}
+
+// parsePresetInput reads a preset from JSON, or from a form whose links
+// field holds the link templates as a JSON array.
+func parsePresetInput(r *http.Request) (presetInput, error) {
+	var in presetInput
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return in, err
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return in, err
+		}
+		in.Name = r.FormValue("name")
+		if raw := strings.TrimSpace(r.FormValue("links")); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &in.Links); err != nil {
+				return in, err
+			}
+		}
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	for i := range in.Links {
+		in.Links[i].Name = strings.TrimSpace(in.Links[i].Name)
+		in.Links[i].URL = strings.TrimSpace(in.Links[i].URL)
+		in.Links[i].Description = strings.TrimSpace(in.Links[i].Description)
+	}
+	return in, nil
+}
+
+func (in presetInput) validate() fieldErrors {
+	errs := fieldErrors{}
+	if in.Name == "" {
+		errs["name"] = "required"
+	} else if utf8.RuneCountInString(in.Name) > maxNameLength {
+		errs["name"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+	if len(in.Links) == 0 {
+		errs["links"] = "at least one link is required"
+	}
+	for i, link := range in.Links {
+		// Templates are checked with a sample value so a placeholder in
+		// the host still has to expand to a usable URL.
+		expanded := link.expand("sample")
+		if linkErrs := expanded.validateFields(); len(linkErrs) > 0 {
+			errs[fmt.Sprintf("links[%d]", i)] = linkErrs.Error()
+		}
+	}
+	return errs
+}
+
+// expand substitutes value for the placeholder and returns the result as
+// link input ready for validation and insertion.
+func (t presetLink) expand(value string) linkInput {
+	return linkInput{
+		Name:        strings.ReplaceAll(t.Name, presetPlaceholder, value),
+		URL:         strings.ReplaceAll(t.URL, presetPlaceholder, value),
+		Description: strings.ReplaceAll(t.Description, presetPlaceholder, value),
+	}
+}
+
+func (s *server) handleListPresets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, links FROM presets ORDER BY name COLLATE NOCASE ASC`)
+	if err != nil {
+		http.Error(w, "failed to load presets", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]presetItem, 0, 8)
+	for rows.Next() {
+		var item presetItem
+		var raw string
+		if err := rows.Scan(&item.ID, &item.Name, &raw); err != nil {
+			http.Error(w, "failed to load presets", http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal([]byte(raw), &item.Links); err != nil {
+			http.Error(w, "failed to load presets", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load presets", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) handleCreatePreset(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parsePresetInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if errs := in.validate(); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+	links, err := json.Marshal(in.Links)
+	if err != nil {
+		http.Error(w, "failed to create preset", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	now := time.Now().Unix()
+	res, err := s.execRetry(ctx, `INSERT INTO presets(name, links, created_at, updated_at) VALUES(?, ?, ?, ?)`, in.Name, string(links), now, now)
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"name": "preset already exists"})
+			return
+		}
+		http.Error(w, "failed to create preset", http.StatusInternalServerError)
+		return
+	}
+	newID, _ := res.LastInsertId()
+	s.markChanged(changeEvent{Type: "preset.created", ID: newID})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusCreated, map[string]string{"id": strconv.FormatInt(newID, 10)})
+		return
+	}
+	s.renderDashboard(w, parseInt64OrZero(r.FormValue("active_panel_id")))
+}
+
+func (s *server) handlePresetActions(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.Error(w, "invalid preset id", http.StatusBadRequest)
+		return
+	}
+	switch r.PathValue("action") {
+	case "update":
+		s.handleUpdatePreset(w, r, id)
+	case "delete":
+		s.handleDeletePreset(w, r, id)
+	case "apply":
+		s.handleApplyPreset(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *server) handleUpdatePreset(w http.ResponseWriter, r *http.Request, id int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	in, err := parsePresetInput(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if errs := in.validate(); len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
+	}
+	links, err := json.Marshal(in.Links)
+	if err != nil {
+		http.Error(w, "failed to update preset", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	res, err := s.execRetry(ctx, `UPDATE presets SET name = ?, links = ?, updated_at = ? WHERE id = ?`, in.Name, string(links), time.Now().Unix(), id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeFieldErrors(w, r, http.StatusConflict, fieldErrors{"name": "preset already exists"})
+			return
+		}
+		http.Error(w, "failed to update preset", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "preset not found", http.StatusNotFound)
+		return
+	}
+	s.markChanged(changeEvent{Type: "preset.updated", ID: id})
+	if isJSONRequest(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.renderDashboard(w, parseInt64OrZero(r.FormValue("active_panel_id")))
+}
+
+func (s *server) handleDeletePreset(w http.ResponseWriter, r *http.Request, id int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var name string
+	if err := s.db.QueryRowContext(ctx, `SELECT name FROM presets WHERE id = ?`, id).Scan(&name); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "failed to delete preset", http.StatusInternalServerError)
+		return
+	}
+	res, err := s.execRetry(ctx, `DELETE FROM presets WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, "failed to delete preset", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		http.Error(w, "preset not found", http.StatusNotFound)
+		return
+	}
+	s.recordAuditLogged(ctx, "delete", "preset", id, map[string]any{"name": name})
+	s.markChanged(changeEvent{Type: "preset.deleted", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+// handleApplyPreset expands a preset with the submitted value and adds
+// every resulting link to one category. Either all links are added or
+// none are.
+func (s *server) handleApplyPreset(w http.ResponseWriter, r *http.Request, id int64) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	categoryID := parseInt64OrZero(r.FormValue("category_id"))
+	value := strings.TrimSpace(r.FormValue("value"))
+	if value == "" {
+		http.Error(w, "value: required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var raw string
+	if err := s.db.QueryRowContext(ctx, `SELECT links FROM presets WHERE id = ?`, id).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "preset not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to apply preset", http.StatusInternalServerError)
+		return
+	}
+	var templates []presetLink
+	if err := json.Unmarshal([]byte(raw), &templates); err != nil {
+		http.Error(w, "failed to apply preset", http.StatusInternalServerError)
+		return
+	}
+
+	inputs := make([]linkInput, 0, len(templates))
+	for _, t := range templates {
+		in := t.expand(value)
+		in.CategoryID = categoryID
+		if errs := s.validateLinkInput(ctx, in); len(errs) > 0 {
+			http.Error(w, errs.Error(), http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, in)
+	}
+	if err := s.checkCategoryCapacity(ctx, s.db, categoryID, len(inputs)); err != nil {
+		writeCapacityError(w, err, "failed to apply preset")
+		return
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to apply preset", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for _, in := range inputs {
+		if _, err := s.insertLink(ctx, tx, in); err != nil {
+			http.Error(w, "failed to apply preset", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to apply preset", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "preset.applied", ID: id})
+	s.renderDashboard(w, activePanelID)
+}
+
+// fieldErrors maps an input field name to a human readable problem. JSON
+// clients receive it as-is; form posts get it flattened into plain text.
+type fieldErrors map[string]string
+
+func (e fieldErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+": "+e[key])
+	}
+	return strings.Join(parts, "; ")
+}
+
+type categoryInput struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ActivePanelID int64  `json:"active_panel_id"`
+}
+
+func parseCategoryInput(r *http.Request) (categoryInput, error) {
+	var in categoryInput
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return in, err
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return in, err
+		}
+		in.Name = r.FormValue("name")
+		in.Description = r.FormValue("description")
+		in.ActivePanelID = parseInt64OrZero(r.FormValue("active_panel_id"))
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Description = strings.TrimSpace(in.Description)
+	return in, nil
+}
+
+func (in categoryInput) validate() fieldErrors {
+	errs := fieldErrors{}
+	if in.Name == "" {
+		errs["name"] = "required"
+	} else if utf8.RuneCountInString(in.Name) > maxNameLength {
+		errs["name"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+	if utf8.RuneCountInString(in.Description) > maxCategoryDescriptionLength {
+		errs["description"] = fmt.Sprintf("must be at most %d characters", maxCategoryDescriptionLength)
+	}
+	return errs
+}
+
+type linkInput struct {
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	Description   string `json:"description"`
+	CustomLogoURL string `json:"custom_logo_url"`
+	CategoryID    int64  `json:"category_id"`
+	ActivePanelID int64  `json:"active_panel_id"`
+	// TargetBlank is nil when the client did not say; new links then open
+	// in a new tab and updates keep the stored preference.
+	TargetBlank *bool `json:"target_blank"`
+	// Confirm asks for confirmation before /go/{id} follows the link, for
+	// URLs that trigger something. Like TargetBlank, nil keeps the stored
+	// value on update; new links default to no confirmation.
+	Confirm *bool `json:"confirm"`
+	// VisibleFrom and VisibleTo are optional "HH:MM" bounds of the daily
+	// window in which the link shows on the dashboard.
+	VisibleFrom string `json:"visible_from"`
+	VisibleTo   string `json:"visible_to"`
+	// Hotkey is an optional key sequence like "g h" that opens the link
+	// from the dashboard. It must be unique within a panel.
+	Hotkey string `json:"hotkey"`
+	// ExpiresAt is an optional future time, RFC 3339 or a datetime-local
+	// value like "2026-05-01T17:00" in the configured time zone, after
+	// which the link is hidden from the dashboard.
+	ExpiresAt string `json:"expires_at"`
+	// Disabled is only set when duplicating a switched-off link; new links
+	// from forms and JSON always start enabled.
+	Disabled bool `json:"-"`
+}
+
+func parseLinkInput(r *http.Request) (linkInput, error) {
+	var in linkInput
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return in, err
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			return in, err
+		}
+		in.Name = r.FormValue("name")
+		in.URL = r.FormValue("url")
+		in.Description = r.FormValue("description")
+		in.CustomLogoURL = r.FormValue("custom_logo_url")
+		in.TargetBlank = parseFormBool(r.Form["target_blank"])
+		in.Confirm = parseFormBool(r.Form["confirm"])
+		in.CategoryID = parseInt64OrZero(r.FormValue("category_id"))
+		in.ActivePanelID = parseInt64OrZero(r.FormValue("active_panel_id"))
+		in.VisibleFrom = r.FormValue("visible_from")
+		in.VisibleTo = r.FormValue("visible_to")
+		in.Hotkey = r.FormValue("hotkey")
+		in.ExpiresAt = r.FormValue("expires_at")
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.URL = strings.TrimSpace(in.URL)
+	in.VisibleFrom = strings.TrimSpace(in.VisibleFrom)
+	in.VisibleTo = strings.TrimSpace(in.VisibleTo)
+	in.Hotkey = strings.Join(strings.Fields(strings.ToLower(in.Hotkey)), " ")
+	in.ExpiresAt = strings.TrimSpace(in.ExpiresAt)
+	in.Description = strings.TrimSpace(in.Description)
+	in.CustomLogoURL = strings.TrimSpace(in.CustomLogoURL)
+	return in, nil
+}
+
+// validateLinkInput is shared by the form and JSON surfaces so both reject
+// the same inputs with the same messages.
+func (s *server) validateLinkInput(ctx context.Context, in linkInput) fieldErrors {
+	errs := in.validateFields()
+	if in.ExpiresAt != "" {
+		if expiresAt, ok := s.parseExpiry(in.ExpiresAt); !ok {
+			errs["expires_at"] = "must be a time like 2026-05-01T17:00"
+		} else if expiresAt <= time.Now().Unix() {
+			errs["expires_at"] = "must be in the future"
+		}
+	}
+	if in.CategoryID == 0 {
+		errs["category_id"] = "required"
+	} else {
+		var id int64
+		err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, in.CategoryID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			errs["category_id"] = "not found"
+		}
+	}
+	return errs
+}
+
+// validateFields checks the link's own fields without touching the
+// database, so it is safe to call while a transaction holds the
+// connection.
+func (in linkInput) validateFields() fieldErrors {
+	errs := fieldErrors{}
+	if in.Name == "" {
+		errs["name"] = "required"
+	} else if utf8.RuneCountInString(in.Name) > maxNameLength {
+		errs["name"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+	if in.URL == "" {
+		errs["url"] = "required"
+	} else if len(in.URL) > maxURLLength {
+		errs["url"] = fmt.Sprintf("must be at most %d characters", maxURLLength)
+	} else if !isLikelyURL(in.URL) {
+		errs["url"] = "must be http or https"
+	}
+	if utf8.RuneCountInString(in.Description) > maxDescriptionLength {
+		errs["description"] = fmt.Sprintf("must be at most %d characters", maxDescriptionLength)
+	}
+	if len(in.CustomLogoURL) > maxURLLength {
+		errs["custom_logo_url"] = fmt.Sprintf("must be at most %d characters", maxURLLength)
+	}
+	from, fromOK := parseTimeOfDay(in.VisibleFrom)
+	if !fromOK {
+		errs["visible_from"] = "must be a time like 09:00"
+	}
+	to, toOK := parseTimeOfDay(in.VisibleTo)
+	if !toOK {
+		errs["visible_to"] = "must be a time like 17:00"
+	}
+	if fromOK && toOK && in.VisibleFrom != "" && from == to {
+		errs["visible_to"] = "must differ from visible_from"
+	}
+	if msg := validateHotkey(in.Hotkey); msg != "" {
+		errs["hotkey"] = msg
+	}
+	return errs
+}
+
+// validateHotkey accepts up to maxHotkeyKeys single letters or digits
+// separated by spaces, as normalized by parseLinkInput. The first key may
+// not be one the dashboard already binds: "/", "n" or a panel number.
+func validateHotkey(hotkey string) string {
+	if hotkey == "" {
+		return ""
+	}
+	keys := strings.Split(hotkey, " ")
+	if len(keys) > maxHotkeyKeys {
+		return fmt.Sprintf("must be at most %d keys", maxHotkeyKeys)
+	}
+	for _, key := range keys {
+		if len(key) != 1 || !(key[0] >= 'a' && key[0] <= 'z' || key[0] >= '0' && key[0] <= '9') {
+			return "must be letters or digits separated by spaces, like g h"
+		}
+	}
+	if first := keys[0][0]; first == 'n' || first >= '0' && first <= '9' {
+		return "must not start with n or a digit, which the dashboard already uses"
+	}
+	return ""
+}
+
+// hotkeyTaken reports whether another link on categoryID's panel already
+// uses hotkey. excludeID is the link being updated, or 0 on create.
+func hotkeyTaken(ctx context.Context, db dbtx, hotkey string, categoryID, excludeID int64) (bool, error) {
+	if hotkey == "" {
+		return false, nil
+	}
+	var id int64
+	err := db.QueryRowContext(ctx,
+		`SELECT l.id FROM links l JOIN categories c ON c.id = l.category_id
+		 WHERE l.hotkey = ? AND l.id <> ?
+		   AND c.panel_id = (SELECT panel_id FROM categories WHERE id = ?)
+		 LIMIT 1`,
+		hotkey, excludeID, categoryID,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// clearHotkeyConflictsTx clears the hotkey of each moved link that another
+// link on its new panel already uses. Moves never fail over a hotkey; the
+// moved link gives its up, and when moved links clash with each other the
+// last one keeps it. ids must only hold links that actually moved.
+func clearHotkeyConflictsTx(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE links SET hotkey = '' WHERE id = ? AND hotkey <> '' AND EXISTS (
+			   SELECT 1 FROM links o
+			   JOIN categories oc ON oc.id = o.category_id
+			   JOIN categories lc ON lc.id = links.category_id
+			   WHERE o.hotkey = links.hotkey AND o.id <> links.id AND oc.panel_id = lc.panel_id)`,
+			id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseExpiry reads an expires_at value as Unix seconds, 0 for an empty
+// one. Values without a zone are in the configured time zone.
+func (s *server) parseExpiry(value string) (int64, bool) {
+	if value == "" {
+		return 0, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), true
+	}
+	loc := s.now().Location()
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.Unix(), true
+		}
+	}
+	return 0, false
+}
+
+// localTime is unixOrZero in the configured time zone.
+func (s *server) localTime(seconds int64) time.Time {
+	t := unixOrZero(seconds)
+	if t.IsZero() {
+		return t
+	}
+	return t.In(s.now().Location())
+}
+
+// parseTimeOfDay reads "HH:MM" as minutes since midnight. An empty value
+// is valid and means no bound.
+func parseTimeOfDay(value string) (int, bool) {
+	if value == "" {
+		return -1, true
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// visibleAt reports whether a link with the given window shows at now.
+// A missing start means midnight and a missing end means the end of the
+// day; a start after the end wraps past midnight, so 22:00-06:00 covers
+// the night.
+func visibleAt(from string, to string, now time.Time) bool {
+	if from == "" && to == "" {
+		return true
+	}
+	start, ok := parseTimeOfDay(from)
+	if !ok || start < 0 {
+		start = 0
+	}
+	end, ok := parseTimeOfDay(to)
+	if !ok || end < 0 {
+		end = 24 * 60
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// writeBodyError reports a request body that could not be read, telling
+// oversized payloads apart from malformed ones.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "invalid form", http.StatusBadRequest)
+}
+
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, status int, errs fieldErrors) {
+	if isJSONRequest(r) {
+		writeJSON(w, status, map[string]fieldErrors{"errors": errs})
+		return
+	}
+	http.Error(w, errs.Error(), status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// handleGo records a visit and redirects to the link. Links flagged with
+// confirm get a confirmation page instead, whose form posts to
+// handleGoConfirmed; nothing is recorded until then.
+func (s *server) handleGo(w http.ResponseWriter, r *http.Request) {
+	s.openLink(w, r, false)
+}
+
+func (s *server) handleGoConfirmed(w http.ResponseWriter, r *http.Request) {
+	s.openLink(w, r, true)
+}
+
+type confirmData struct {
+	ID   int64
+	Name string
+	URL  string
+}
+
+func (s *server) openLink(w http.ResponseWriter, r *http.Request, confirmed bool) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var name, target string
+	var confirm, enabled bool
+	if err := s.db.QueryRowContext(ctx, `SELECT name, url, confirm, enabled FROM links WHERE id = ?`, id).Scan(&name, &target, &confirm, &enabled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to open link", http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		http.Error(w, "link is disabled", http.StatusForbidden)
+		return
+	}
+	if err := s.cipher.openAll(&target); err != nil {
+		http.Error(w, "failed to open link", http.StatusInternalServerError)
+		return
+	}
+	if confirm && !confirmed {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := s.templates.ExecuteTemplate(w, "confirm.html", confirmData{ID: id, Name: name, URL: target}); err != nil {
+			http.Error(w, "failed to render template", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := s.recordVisit(ctx, id); err != nil {
+		log.Printf("record visit for link %d: %v", id, err)
+	} else {
+		s.markChanged(changeEvent{Type: "link.opened", ID: id})
+	}
+	// 303 so the browser follows a confirmed POST with a GET.
+	status := http.StatusFound
+	if r.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+	http.Redirect(w, r, target, status)
+}
+
+// linkVisitLimit caps how many visits are kept per link; older rows are
+// pruned on every insert. click_count keeps counting past it.
+const linkVisitLimit = 500
+
+// recordVisit bumps the link's click count and appends to its visit log.
+func (s *server) recordVisit(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.ExecContext(ctx, `UPDATE links SET click_count = click_count + 1, last_opened_at = ? WHERE id = ?`, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO link_visits(link_id, visited_at) VALUES(?, ?)`, id, now); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM link_visits WHERE link_id = ? AND id NOT IN (SELECT id FROM link_visits WHERE link_id = ? ORDER BY id DESC LIMIT ?)`,
+		id, id, linkVisitLimit,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type linkVisit struct {
+	VisitedAt time.Time `json:"visited_at"`
+}
+
+// handleLinkVisits lists a link's most recent visits, newest first.
+func (s *server) handleLinkVisits(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.Error(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, linkVisitLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var exists int64
+	if err := s.db.QueryRowContext(ctx, `SELECT id FROM links WHERE id = ?`, id).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load visits", http.StatusInternalServerError)
+		return
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT visited_at FROM link_visits WHERE link_id = ? ORDER BY id DESC LIMIT ?`,
+		id, limit,
+	)
+	if err != nil {
+		http.Error(w, "failed to load visits", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	items := make([]linkVisit, 0, limit)
+	for rows.Next() {
+		var visitedAt int64
+		if err := rows.Scan(&visitedAt); err != nil {
+			http.Error(w, "failed to load visits", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, linkVisit{VisitedAt: time.Unix(visitedAt, 0).UTC()})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load visits", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Number of buckets in each series of /api/links/{id}/stats, ending with
+// the current day, week and month.
+const (
+	statsDays   = 14
+	statsWeeks  = 8
+	statsMonths = 12
+)
+
+// SQLite expressions mapping visited_at to the UTC date that starts its
+// day, its Monday-based week, and its month.
+const (
+	visitDayBucket   = `date(visited_at, 'unixepoch')`
+	visitWeekBucket  = `date(visited_at, 'unixepoch', 'weekday 0', '-6 days')`
+	visitMonthBucket = `strftime('%Y-%m-01', visited_at, 'unixepoch')`
+)
+
+type visitBucket struct {
+	Start string `json:"start"`
+	Count int    `json:"count"`
+}
+
+type linkStats struct {
+	LinkID     int64         `json:"link_id"`
+	ClickCount int           `json:"click_count"`
+	Days       []visitBucket `json:"days"`
+	Weeks      []visitBucket `json:"weeks"`
+	Months     []visitBucket `json:"months"`
+}
+
+// handleLinkStats rolls a link's visit log up into daily, weekly and
+// monthly counts. Every bucket in range is listed, with zero for those
+// without visits. Only the visits linkVisitLimit keeps are counted, while
+// click_count is the all-time total.
+func (s *server) handleLinkStats(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64OrZero(r.PathValue("id"))
+	if id == 0 {
+		http.Error(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	stats := linkStats{LinkID: id}
+	if err := s.db.QueryRowContext(ctx, `SELECT click_count FROM links WHERE id = ?`, id).Scan(&stats.ClickCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load link stats", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	series := []struct {
+		bucket string
+		starts []time.Time
+		out    *[]visitBucket
+	}{
+		{visitDayBucket, bucketStarts(today, statsDays, 0, 1), &stats.Days},
+		{visitWeekBucket, bucketStarts(monday, statsWeeks, 0, 7), &stats.Weeks},
+		{visitMonthBucket, bucketStarts(thisMonth, statsMonths, 1, 0), &stats.Months},
+	}
+	for _, sr := range series {
+		counts, err := s.countVisits(ctx, sr.bucket, id, sr.starts[0])
+		if err != nil {
+			http.Error(w, "failed to load link stats", http.StatusInternalServerError)
+			return
+		}
+		buckets := make([]visitBucket, len(sr.starts))
+		for i, start := range sr.starts {
+			key := start.Format("2006-01-02")
+			buckets[i] = visitBucket{Start: key, Count: counts[key]}
+		}
+		*sr.out = buckets
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// bucketStarts returns n bucket start times, oldest first, ending with
+// last and spaced months and days apart.
+func bucketStarts(last time.Time, n, months, days int) []time.Time {
+	starts := make([]time.Time, n)
+	for i := range starts {
+		back := n - 1 - i
+		starts[i] = last.AddDate(0, -months*back, -days*back)
+	}
+	return starts
+}
+
+// countVisits counts the link's visits since since, grouped by bucket,
+// one of the visit*Bucket expressions.
+func (s *server) countVisits(ctx context.Context, bucket string, linkID int64, since time.Time) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+bucket+` AS bucket, COUNT(*) FROM link_visits WHERE link_id = ? AND visited_at >= ? GROUP BY bucket`,
+		linkID, since.Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		counts[key] = count
+	}
+	return counts, rows.Err()
+}
+
+// Bounds for /api/stats/top.
+const (
+	defaultTopPeriodDays = 7
+	maxTopPeriodDays     = 365
+	defaultTopLimit      = 10
+	maxTopLimit          = 100
+)
+
+type flatLink struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Description  string `json:"description"`
+	CategoryID   int64  `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	PanelID      int64  `json:"panel_id"`
+	ClickCount   int    `json:"click_count"`
+	Hotkey       string `json:"hotkey"`
+	Disabled     bool   `json:"disabled"`
+	// ExpiresAt is null for links that never expire. Expired links are
+	// still listed so clients can see them before the sweep deletes them.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type flatLinkPage struct {
+	Total  int        `json:"total"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+	Links  []flatLink `json:"links"`
+}
+
+// handleListLinks lists every link in one flat array, in dashboard order,
+// optionally narrowed to a category or a search term and paged with
+// limit and offset. Total counts the matches before paging. Links carry
+// no tags, so a tag filter is refused rather than ignored.
+func (s *server) handleListLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("tag") {
+		http.Error(w, "tag filtering is not supported: links have no tags", http.StatusBadRequest)
+		return
+	}
+	filter := linkFilter{Search: strings.TrimSpace(query.Get("q")), Order: flatLinkOrder}
+	if raw := strings.TrimSpace(query.Get("category_id")); raw != "" {
+		filter.CategoryID = parseInt64OrZero(raw)
+		if filter.CategoryID <= 0 {
+			http.Error(w, "category_id must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	// Encrypted URLs cannot be matched in SQL, and matching them in Go
+	// would break paging, so q only searches names then.
+	filter.NameOnly = s.cipher != nil
+	var err error
+	filter.Limit, filter.Offset, err = parsePage(r, maxListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	const from = ` FROM links l JOIN categories c ON c.id = l.category_id`
+	page := flatLinkPage{Limit: filter.Limit, Offset: filter.Offset, Links: []flatLink{}}
+	countFilter := filter
+	countFilter.Order, countFilter.Limit, countFilter.Offset = "", 0, 0
+	countSQL, countArgs := countFilter.apply(newSQLQuery(`SELECT COUNT(*)` + from)).build()
+	if err := s.db.QueryRowContext(ctx, countSQL, countArgs...).Scan(&page.Total); err != nil {
+		http.Error(w, "failed to list links", http.StatusInternalServerError)
+		return
+	}
+
+	listSQL, listArgs := filter.apply(newSQLQuery(
+		`SELECT l.id, l.name, l.url, l.description, l.category_id, c.name, c.panel_id, l.click_count, l.hotkey, l.enabled = 0, l.expires_at` + from,
+	)).build()
+	rows, err := s.db.QueryContext(ctx, listSQL, listArgs...)
+	if err != nil {
+		http.Error(w, "failed to list links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link flatLink
+		var expiresAt int64
+		if err := rows.Scan(&link.ID, &link.Name, &link.URL, &link.Description, &link.CategoryID, &link.CategoryName,
+			&link.PanelID, &link.ClickCount, &link.Hotkey, &link.Disabled, &expiresAt); err != nil {
+			http.Error(w, "failed to list links", http.StatusInternalServerError)
+			return
+		}
+		if expiresAt > 0 {
+			expires := time.Unix(expiresAt, 0).UTC()
+			link.ExpiresAt = &expires
+		}
+		if err := s.cipher.openAll(&link.URL, &link.Description); err != nil {
+			http.Error(w, "failed to list links", http.StatusInternalServerError)
+			return
+		}
+		page.Links = append(page.Links, link)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to list links", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+type topLink struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	CategoryID int64  `json:"category_id"`
+	Visits     int    `json:"visits"`
+}
+
+// handleTopLinks lists the links opened most often in the last period,
+// given in days like "7d". Links without visits in the window are left
+// out, so a quiet period answers with an empty list.
+func (s *server) handleTopLinks(w http.ResponseWriter, r *http.Request) {
+	days := defaultTopPeriodDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("period")); raw != "" {
+		parsed, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if !strings.HasSuffix(raw, "d") || err != nil || parsed < 1 || parsed > maxTopPeriodDays {
+			http.Error(w, fmt.Sprintf("period must be a number of days like 7d, at most %dd", maxTopPeriodDays), http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+	limit := defaultTopLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxTopLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT l.id, l.name, l.url, l.category_id, COUNT(*) AS visits
+		 FROM link_visits v
+		 JOIN links l ON l.id = v.link_id
+		 WHERE v.visited_at >= ?
+		 GROUP BY l.id
+		 ORDER BY visits DESC, l.id ASC
+		 LIMIT ?`,
+		since.Unix(), limit,
+	)
+	if err != nil {
+		http.Error(w, "failed to load top links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	links := make([]topLink, 0, limit)
+	for rows.Next() {
+		var link topLink
+		if err := rows.Scan(&link.ID, &link.Name, &link.URL, &link.CategoryID, &link.Visits); err != nil {
+			http.Error(w, "failed to load top links", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&link.URL); err != nil {
+			http.Error(w, "failed to load top links", http.StatusInternalServerError)
+			return
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load top links", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"period": fmt.Sprintf("%dd", days),
+		"since":  since.UTC().Truncate(time.Second),
+		"links":  links,
+	})
+}
+
+// auditLogLimit bounds the audit log; older entries are swept as new ones
+// are added.
+const auditLogLimit = 1000
+
+// recordAudit appends an entry to the audit log. Each entry's hash covers
+// its fields and the previous entry's hash, so editing or deleting a row
+// in the middle breaks the chain from there on.
+func recordAudit(ctx context.Context, db dbtx, action, entityType string, entityID int64, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	body, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	var prev string
+	if err := db.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prev); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	now := time.Now().Unix()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s\n%d\n%s\n%d", prev, action, entityType, entityID, body, now)))
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO audit_log(action, entity_type, entity_id, details_json, created_at, hash) VALUES(?, ?, ?, ?, ?, ?)`,
+		action, entityType, entityID, string(body), now, hex.EncodeToString(sum[:]),
+	); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`DELETE FROM audit_log WHERE id NOT IN (SELECT id FROM audit_log ORDER BY id DESC LIMIT ?)`, auditLogLimit,
+	)
+	return err
+}
+
+// recordAuditLogged records an audit entry for a change that is already
+// committed, so a failure is logged rather than failing the request.
+func (s *server) recordAuditLogged(ctx context.Context, action, entityType string, entityID int64, details map[string]any) {
+	if err := recordAudit(ctx, s.db, action, entityType, entityID, details); err != nil {
+		log.Printf("audit %s %s %d: %v", action, entityType, entityID, err)
+	}
+}
+
+type auditEntry struct {
+	ID         int64           `json:"id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Hash       string          `json:"hash"`
+}
+
+// handleAuditLog lists the most recent audit entries, newest first.
+func (s *server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, auditLogLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, action, entity_type, entity_id, details_json, created_at, hash FROM audit_log ORDER BY id DESC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		http.Error(w, "failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	items := make([]auditEntry, 0, limit)
+	for rows.Next() {
+		var item auditEntry
+		var details string
+		var createdAt int64
+		if err := rows.Scan(&item.ID, &item.Action, &item.EntityType, &item.EntityID, &details, &createdAt, &item.Hash); err != nil {
+			http.Error(w, "failed to load audit log", http.StatusInternalServerError)
+			return
+		}
+		item.Details = json.RawMessage(details)
+		item.CreatedAt = time.Unix(createdAt, 0).UTC()
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// maxListLimit caps the limit parameter of the stale and expiring lists.
+const maxListLimit = 500
+
+type staleData struct {
+	Days  int
+	Links []dashboardLink
+}
+
+func (s *server) handleStaleLinks(w http.ResponseWriter, r *http.Request) {
+	days := 90
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+	limit, offset, err := parsePage(r, maxListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := linkFilter{
+		OpenedBefore: time.Now().AddDate(0, 0, -days).Unix(),
+		Order:        staleLinkOrder,
+		Limit:        limit,
+		Offset:       offset,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	query, args := filter.apply(newSQLQuery(
+		`SELECT l.id, l.name, l.url, l.category_id, c.name, l.click_count, l.last_opened_at
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id`,
+	)).build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "failed to load stale links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	data := staleData{Days: days, Links: []dashboardLink{}}
+	for rows.Next() {
+		var id, categoryID, lastOpened int64
+		var item dashboardLink
+		if err := rows.Scan(&id, &item.Name, &item.URL, &categoryID, &item.CategoryName, &item.ClickCount, &lastOpened); err != nil {
+			http.Error(w, "failed to load stale links", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&item.URL); err != nil {
+			http.Error(w, "failed to load stale links", http.StatusInternalServerError)
+			return
+		}
+		item.ID = strconv.FormatInt(id, 10)
+		item.CategoryID = strconv.FormatInt(categoryID, 10)
+		item.LastOpenedAt = unixOrZero(lastOpened)
+		data.Links = append(data.Links, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load stale links", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "stale.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+type expiringData struct {
+	Days  int
+	Now   time.Time
+	Links []dashboardLink
+}
+
+// handleExpiringLinks lists links whose expiry falls within the next
+// days, soonest first. Links that have expired but are not yet swept
+// are listed too, ahead of the rest.
+func (s *server) handleExpiringLinks(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+	limit, offset, err := parsePage(r, maxListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := linkFilter{
+		ExpiresBy: time.Now().AddDate(0, 0, days).Unix(),
+		Order:     expiringLinkOrder,
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	query, args := filter.apply(newSQLQuery(
+		`SELECT l.id, l.name, l.url, l.category_id, c.name, l.expires_at
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id`,
+	)).build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "failed to load expiring links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	data := expiringData{Days: days, Now: time.Now(), Links: []dashboardLink{}}
+	for rows.Next() {
+		var id, categoryID, expiresAt int64
+		var item dashboardLink
+		if err := rows.Scan(&id, &item.Name, &item.URL, &categoryID, &item.CategoryName, &expiresAt); err != nil {
+			http.Error(w, "failed to load expiring links", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&item.URL); err != nil {
+			http.Error(w, "failed to load expiring links", http.StatusInternalServerError)
+			return
+		}
+		item.ID = strconv.FormatInt(id, 10)
+		item.CategoryID = strconv.FormatInt(categoryID, 10)
+		item.ExpiresAt = s.localTime(expiresAt)
+		data.Links = append(data.Links, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load expiring links", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "expiring.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+type categoryItem struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// handleListCategories returns just the unarchived categories of one
+// panel, in the dashboard's order, for clients that only need a category
+// picker.
+func (s *server) handleListCategories(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	panelID, err := s.resolvePanelID(ctx, parseInt64OrZero(r.URL.Query().Get("panel_id")))
+	if err != nil {
+		http.Error(w, "failed to load categories", http.StatusInternalServerError)
+		return
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name FROM categories WHERE panel_id = ? AND archived = 0 ORDER BY `+s.categorySortOrder(),
+		panelID,
+	)
+	if err != nil {
+		http.Error(w, "failed to load categories", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := make([]categoryItem, 0, 16)
+	for rows.Next() {
+		var item categoryItem
+		if err := rows.Scan(&item.ID, &item.Name); err != nil {
+			http.Error(w, "failed to load categories", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load categories", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+type archivedData struct {
+	FormPanelID string
+	Categories  []dashboardCategory
+}
+
+// handleArchivedCategories renders the archived categories of a panel so
+// they can be reviewed and brought back.
+func (s *server) handleArchivedCategories(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getDashboardData(r.Context(), parseInt64OrZero(r.URL.Query().Get("panel_id")), defaultLinkSort)
+	if err != nil {
+		http.Error(w, "failed to load archived categories", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	view := archivedData{FormPanelID: data.FormPanelID, Categories: data.ArchivedCategories}
+	if err := s.templates.ExecuteTemplate(w, "archived.html", view); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// handleOnboarding renders the first-run block for a panel on its own.
+// It answers 204 once the panel has a category, so a client polling it
+// knows to stop.
+func (s *server) handleOnboarding(w http.ResponseWriter, r *http.Request) {
+	data, err := s.getDashboardData(r.Context(), parseInt64OrZero(r.URL.Query().Get("panel_id")), defaultLinkSort)
+	if err != nil {
+		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+	if !data.Empty() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "onboarding", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// handleCategoryLinkURLs lists the URLs of one category in display order,
+// for clients that open the whole category at once.
+func (s *server) handleCategoryLinkURLs(w http.ResponseWriter, r *http.Request) {
+	categoryID := parseInt64OrZero(r.PathValue("id"))
+	if categoryID == 0 {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var exists int64
+	if err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, categoryID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load links", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT url FROM links WHERE category_id = ? ORDER BY position ASC, id ASC`, categoryID)
+	if err != nil {
+		http.Error(w, "failed to load links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	urls := make([]string, 0, 16)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			http.Error(w, "failed to load links", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&url); err != nil {
+			http.Error(w, "failed to load links", http.StatusInternalServerError)
+			return
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load links", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, urls)
+}
+
+type impactLink struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type categoryImpact struct {
+	LinkCount int          `json:"link_count"`
+	Links     []impactLink `json:"links"`
+}
+
+// handleCategoryImpact lists the links that deleting the category would
+// remove, so a client can ask before calling delete.
+func (s *server) handleCategoryImpact(w http.ResponseWriter, r *http.Request) {
+	categoryID := parseInt64OrZero(r.PathValue("id"))
+	if categoryID == 0 {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var exists int64
+	if err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, categoryID).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load category impact", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, url FROM links WHERE category_id = ? ORDER BY position ASC, id ASC`, categoryID)
+	if err != nil {
+		http.Error(w, "failed to load category impact", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	impact := categoryImpact{Links: make([]impactLink, 0, 16)}
+	for rows.Next() {
+		var link impactLink
+		if err := rows.Scan(&link.ID, &link.Name, &link.URL); err != nil {
+			http.Error(w, "failed to load category impact", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&link.URL); err != nil {
+			http.Error(w, "failed to load category impact", http.StatusInternalServerError)
+			return
+		}
+		impact.Links = append(impact.Links, link)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load category impact", http.StatusInternalServerError)
+		return
+	}
+	impact.LinkCount = len(impact.Links)
+	writeJSON(w, http.StatusOK, impact)
+}
+
+// shareTokenBytes is the amount of randomness in a category share token,
+// which is all that guards a shared category.
+const shareTokenBytes = 32
+
+// newRandomToken returns a random URL-safe token of shareTokenBytes, for
+// share links and unlock sessions.
+func newRandomToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// handleShareCategory creates a read-only share of the category and
+// answers with its token; the dashboard shows the link as a notice.
+// Every call makes a new token, so a category can be shared with several
+// people and each share revoked on its own.
+func (s *server) handleShareCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var name string
+	if err := s.db.QueryRowContext(ctx, `SELECT name FROM categories WHERE id = ?`, categoryID).Scan(&name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to share category", http.StatusInternalServerError)
+		return
+	}
+	token, err := newRandomToken()
+	if err != nil {
+		http.Error(w, "failed to share category", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.execRetry(ctx,
+		`INSERT INTO category_shares(token, category_id, created_at) VALUES(?, ?, ?)`,
+		token, categoryID, time.Now().Unix(),
+	); err != nil {
+		http.Error(w, "failed to share category", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "category.shared", ID: categoryID})
+	path := "/backend/share/" + token
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusCreated, map[string]string{"token": token, "path": path})
+		return
+	}
+	s.renderDashboardNotice(w, activePanelID, fmt.Sprintf("%s is shared read-only at %s", name, path))
+}
+
+// handleUnshareCategory revokes the share named by token, or every share
+// of the category when token is empty.
+func (s *server) handleUnshareCategory(w http.ResponseWriter, r *http.Request, categoryID int64) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	token := strings.TrimSpace(r.FormValue("token"))
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	query, args := `DELETE FROM category_shares WHERE category_id = ?`, []any{categoryID}
+	if token != "" {
+		query, args = query+` AND token = ?`, append(args, token)
+	}
+	res, err := s.execRetry(ctx, query, args...)
+	if err != nil {
+		http.Error(w, "failed to revoke share", http.StatusInternalServerError)
+		return
+	}
+	revoked, _ := res.RowsAffected()
+	if revoked == 0 && token != "" {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+	// Details hold the count only: a token is a share's whole secret.
+	if revoked > 0 {
+		s.recordAuditLogged(ctx, "unshare", "category", categoryID, map[string]any{"revoked": revoked})
+	}
+	s.markChanged(changeEvent{Type: "category.unshared", ID: categoryID})
+	if isJSONRequest(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+// shareData feeds share.html, the read-only page for a shared category.
+type shareData struct {
+	Name        string
+	Description string
+	Links       []dashboardLink
+}
+
+// handleSharedCategory renders the category behind a share token with its
+// links and nothing else: no ids, actions or other categories. Links go
+// straight to their URLs rather than through /go/{id}.
+func (s *server) handleSharedCategory(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var categoryID int64
+	var data shareData
+	err := s.db.QueryRowContext(ctx,
+		`SELECT c.id, c.name, c.description FROM category_shares s JOIN categories c ON c.id = s.category_id WHERE s.token = ?`,
+		token,
+	).Scan(&categoryID, &data.Name, &data.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "failed to load shared category", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		// Disabled links stay off the public page; openLink refuses them too.
+		`SELECT name, url, description, visible_from, visible_to, expires_at FROM links WHERE category_id = ? AND enabled = 1 ORDER BY position ASC, id ASC`,
+		categoryID,
+	)
+	if err != nil {
+		http.Error(w, "failed to load shared category", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	// The public page hides the same links the dashboard does right now.
+	now := s.now()
+	for rows.Next() {
+		var link dashboardLink
+		var expiresAt int64
+		if err := rows.Scan(&link.Name, &link.URL, &link.Description, &link.VisibleFrom, &link.VisibleTo, &expiresAt); err != nil {
+			http.Error(w, "failed to load shared category", http.StatusInternalServerError)
+			return
+		}
+		link.ExpiresAt = s.localTime(expiresAt)
+		if !visibleAt(link.VisibleFrom, link.VisibleTo, now) || link.ExpiredAt(now) {
+			continue
+		}
+		if err := s.cipher.openAll(&link.URL, &link.Description); err != nil {
+			http.Error(w, "failed to load shared category", http.StatusInternalServerError)
+			return
+		}
+		link.DescriptionHTML = renderMarkdown(link.Description)
+		link.IconDataURI = monogramDataURI(link.Name, link.URL)
+		data.Links = append(data.Links, link)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load shared category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	// Keep the token out of the Referer sent to the shared sites.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if err := s.templates.ExecuteTemplate(w, "share.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// Limits for importing a pasted list of URLs. Automatic names fetch each
+// page, so those requests run a few at a time under their own deadline.
+const (
+	maxImportURLs      = 200
+	importFetchWorkers = 4
+	importFetchTimeout = 30 * time.Second
+)
+
+// handleImportURLs adds one link per non-blank line of the urls field.
+// Lines that fail validation are skipped and listed in the response; the
+// rest are inserted in a single transaction.
+func (s *server) handleImportURLs(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	categoryID := parseInt64OrZero(r.FormValue("category_id"))
+	autoname := r.FormValue("autoname") == "1"
+	skipExisting, err := parseSkipExisting(r.FormValue("skip_existing"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inputs, failures, err := parseImportURLs(r.FormValue("urls"), categoryID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Drop already saved links before fetching titles for them.
+	inputs, skipped, err := s.skipSavedURLs(ctx, inputs, skipExisting)
+	if err != nil {
+		http.Error(w, "failed to import urls", http.StatusInternalServerError)
+		return
+	}
+
+	if autoname && len(inputs) > 0 {
+		fetchCtx, cancelFetch := context.WithTimeout(r.Context(), importFetchTimeout)
+		titles := fetchPageTitles(fetchCtx, inputs)
+		cancelFetch()
+		for i, title := range titles {
+			if title != "" {
+				if utf8.RuneCountInString(title) > maxNameLength {
+					title = string([]rune(title)[:maxNameLength])
+				}
+				inputs[i].Name = title
+			}
+		}
+	}
+
+	if len(inputs) > 0 {
+		if errs := s.validateLinkInput(ctx, inputs[0]); errs["category_id"] != "" {
+			http.Error(w, "category_id: "+errs["category_id"], http.StatusBadRequest)
+			return
+		}
+		if err := s.checkCategoryCapacity(ctx, s.db, categoryID, len(inputs)); err != nil {
+			writeCapacityError(w, err, "failed to import urls")
+			return
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			http.Error(w, "failed to import urls", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		for _, in := range inputs {
+			if _, err := s.insertLink(ctx, tx, in); err != nil {
+				http.Error(w, "failed to import urls", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "failed to import urls", http.StatusInternalServerError)
+			return
+		}
+		s.markChanged(changeEvent{Type: "links.imported"})
+	}
+
+	if len(inputs)+skipped == 0 {
+		http.Error(w, "no valid urls: "+joinImportFailures(failures), http.StatusBadRequest)
+		return
+	}
+	notice := fmt.Sprintf("Imported %d links.", len(inputs))
+	if skipped > 0 {
+		notice += fmt.Sprintf(" Skipped %d already saved.", skipped)
+	}
+	if len(failures) > 0 {
+		notice += fmt.Sprintf(" Skipped %d: %s", len(failures), joinImportFailures(failures))
+	}
+	s.renderDashboardNotice(w, activePanelID, notice)
+}
+
+// importFailure is one line of an import that failed validation.
+type importFailure struct {
+	Line   int    `json:"line,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (f importFailure) String() string {
+	if f.Line == 0 {
+		return f.Reason
+	}
+	return fmt.Sprintf("line %d: %s", f.Line, f.Reason)
+}
+
+func joinImportFailures(failures []importFailure) string {
+	parts := make([]string, len(failures))
+	for i, f := range failures {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// parseImportURLs turns each non-blank line of text into a link for
+// categoryID, named after its host. Invalid lines are returned as
+// failures; the error covers input that cannot be imported at all.
+func parseImportURLs(text string, categoryID int64) ([]linkInput, []importFailure, error) {
+	var inputs []linkInput
+	var failures []importFailure
+	for idx, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		in := linkInput{URL: normalizeImportURL(line), CategoryID: categoryID}
+		in.Name = hostName(in.URL)
+		if errs := in.validateFields(); len(errs) > 0 {
+			failures = append(failures, importFailure{Line: idx + 1, Reason: errs.Error()})
+			continue
+		}
+		inputs = append(inputs, in)
+	}
+	if len(inputs)+len(failures) == 0 {
+		return nil, nil, errors.New("urls: required")
+	}
+	if len(inputs)+len(failures) > maxImportURLs {
+		return nil, nil, fmt.Errorf("urls: at most %d lines per import", maxImportURLs)
+	}
+	return inputs, failures, nil
+}
+
+// skipSavedURLs drops the inputs already saved under the skip_existing
+// mode, returning the rest and how many were dropped.
+func (s *server) skipSavedURLs(ctx context.Context, inputs []linkInput, mode string) ([]linkInput, int, error) {
+	if mode == "" || len(inputs) == 0 {
+		return inputs, 0, nil
+	}
+	saved, err := s.loadSavedURLs(ctx, s.db, mode)
+	if err != nil {
+		return nil, 0, err
+	}
+	kept := inputs[:0]
+	for _, in := range inputs {
+		if !saved.seen(in.CategoryID, in.URL) {
+			kept = append(kept, in)
+		}
+	}
+	return kept, len(inputs) - len(kept), nil
+}
+
+// normalizeImportURL assumes https for bare hosts like "example.com/docs".
+func normalizeImportURL(raw string) string {
+	if !strings.Contains(raw, "://") {
+		return "https://" + raw
+	}
+	return raw
+}
+
+// Values of the skip_existing import option: skip links whose URL is
+// already saved in the target category, or in any category.
+const (
+	skipExistingCategory = "1"
+	skipExistingAnywhere = "anywhere"
+)
+
+func parseSkipExisting(raw string) (string, error) {
+	switch raw = strings.TrimSpace(raw); raw {
+	case "", "0":
+		return "", nil
+	case skipExistingCategory, skipExistingAnywhere:
+		return raw, nil
+	}
+	return "", fmt.Errorf("skip_existing: must be 1 or anywhere, got %q", raw)
+}
+
+// comparableURL reduces a link URL to the form used to tell whether two
+// links point at the same page: http and https are treated alike, the
+// host is lowercased without "www." or a default port, and the fragment
+// and any trailing slash are dropped. The query is kept as is.
+func comparableURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if port := parsed.Port(); port != "" && !(scheme == "http" && port == "80") && !(scheme == "https" && port == "443") {
+		host = net.JoinHostPort(host, port)
+	}
+	key := host + strings.TrimSuffix(parsed.EscapedPath(), "/")
+	if scheme != "http" && scheme != "https" {
+		key = scheme + "://" + key
+	}
+	if parsed.RawQuery != "" {
+		key += "?" + parsed.RawQuery
+	}
+	return key
+}
+
+// savedURLs is the set of comparable URLs already stored, keyed per
+// category unless anywhere is set.
+type savedURLs struct {
+	anywhere bool
+	keys     map[string]bool
+}
+
+// loadSavedURLs reads every link URL for a skip_existing import.
+func (s *server) loadSavedURLs(ctx context.Context, db dbtx, mode string) (*savedURLs, error) {
+	saved := &savedURLs{anywhere: mode == skipExistingAnywhere, keys: make(map[string]bool)}
+	rows, err := db.QueryContext(ctx, `SELECT category_id, url FROM links`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var categoryID int64
+		var rawURL string
+		if err := rows.Scan(&categoryID, &rawURL); err != nil {
+			return nil, err
+		}
+		if err := s.cipher.openAll(&rawURL); err != nil {
+			return nil, err
+		}
+		saved.keys[saved.key(categoryID, rawURL)] = true
+	}
+	return saved, rows.Err()
+}
+
+func (u *savedURLs) key(categoryID int64, rawURL string) string {
+	if u.anywhere {
+		return comparableURL(rawURL)
+	}
+	return strconv.FormatInt(categoryID, 10) + " " + comparableURL(rawURL)
+}
+
+// seen reports whether rawURL is already saved and otherwise records it,
+// so a URL repeated within one import is only added once.
+func (u *savedURLs) seen(categoryID int64, rawURL string) bool {
+	key := u.key(categoryID, rawURL)
+	if u.keys[key] {
+		return true
+	}
+	u.keys[key] = true
+	return false
+}
+
+// inboxName is the category /actions/capture files links into, created
+// on the first panel when missing.
+const inboxName = "Inbox"
+
+// captureFetchTimeout bounds the title lookup for a captured link, so a
+// slow site only costs the capture its name.
+const captureFetchTimeout = 5 * time.Second
+
+type captureData struct {
+	ID       int64
+	Name     string
+	URL      string
+	Category string
+}
+
+// handleCapture saves a bare url into the Inbox category, named after the
+// page title when the page answers in time and after its host otherwise.
+// It answers with a tiny standalone page for share-sheet targets.
+func (s *server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	rawURL := strings.TrimSpace(r.FormValue("url"))
+	if rawURL == "" {
+		http.Error(w, "url: required", http.StatusBadRequest)
+		return
+	}
+	in := linkInput{URL: normalizeImportURL(rawURL)}
+	in.Name = hostName(in.URL)
+	if errs := in.validateFields(); len(errs) > 0 {
+		http.Error(w, errs.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fetchCtx, cancelFetch := context.WithTimeout(r.Context(), captureFetchTimeout)
+	if title := fetchPageTitles(fetchCtx, []linkInput{in})[0]; title != "" {
+		if utf8.RuneCountInString(title) > maxNameLength {
+			title = string([]rune(title)[:maxNameLength])
+		}
+		in.Name = title
+	}
+	cancelFetch()
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	panelID, err := s.resolvePanelID(ctx, 0)
+	if err != nil {
+		http.Error(w, "failed to capture link", http.StatusInternalServerError)
+		return
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to capture link", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if in.CategoryID, err = s.ensureCategoryTx(ctx, tx, panelID, inboxName); err != nil {
+		writeCapacityError(w, err, "failed to capture link")
+		return
+	}
+	if err := s.checkCategoryCapacity(ctx, tx, in.CategoryID, 1); err != nil {
+		writeCapacityError(w, err, "failed to capture link")
+		return
+	}
+	newID, err := s.insertLink(ctx, tx, in)
+	if err != nil {
+		http.Error(w, "failed to capture link", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to capture link", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "link.created", ID: newID})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	data := captureData{ID: newID, Name: in.Name, URL: in.URL, Category: inboxName}
+	if err := s.templates.ExecuteTemplate(w, "capture.html", data); err != nil {
+		log.Printf("render capture: %v", err)
+	}
+}
+
+// hostName is the default name for an imported link: its host without a
+// leading "www.".
+func hostName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
+
+// fetchPageTitles returns the OpenGraph or document title of each link's
+// page, or "" where the page could not be fetched in time.
+func fetchPageTitles(ctx context.Context, inputs []linkInput) []string {
+	titles := make([]string, len(inputs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range importFetchWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				og, err := fetchOpenGraph(ctx, inputs[i].URL)
+				if err != nil {
+					continue
+				}
+				titles[i] = og.Title
+				if titles[i] == "" {
+					titles[i] = og.PageTitle
+				}
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return titles
+}
+
+type quickOpenItem struct {
+	Name         string     `json:"name"`
+	URL          string     `json:"url"`
+	CategoryName string     `json:"category_name"`
+	LastStatus   int        `json:"last_status"`
+	LastChecked  *time.Time `json:"last_checked"`
+}
+
+// maxSearchCandidates bounds how many LIKE matches are scored in Go for a
+// single search.
+const maxSearchCandidates = 1000
+
+// searchResult is a link that matched a search, with its rank score.
+type searchResult struct {
+	ID         int64
+	Link       quickOpenItem
+	ClickCount int
+	Score      float64
+}
+
+// handleQuickOpen serves the ranked link search as flat JSON for omnibar
+// clients; see searchLinks for the ranking.
+func (s *server) handleQuickOpen(w http.ResponseWriter, r *http.Request) {
+	query, limit, ok := parseSearchParams(w, r)
+	if !ok {
+		return
+	}
+	items := make([]quickOpenItem, 0, limit)
+	if query == "" {
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	results, err := s.searchLinks(ctx, query, limit)
+	if err != nil {
+		http.Error(w, "failed to search links", http.StatusInternalServerError)
+		return
+	}
+	for _, result := range results {
+		items = append(items, result.Link)
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+type searchData struct {
+	Query   string
+	Results []searchResult
+}
+
+// handleSearchPartial renders the same ranked search as HTML.
+func (s *server) handleSearchPartial(w http.ResponseWriter, r *http.Request) {
+	query, limit, ok := parseSearchParams(w, r)
+	if !ok {
+		return
+	}
+	data := searchData{Query: query, Results: []searchResult{}}
+	if query != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		results, err := s.searchLinks(ctx, query, limit)
+		if err != nil {
+			http.Error(w, "failed to search links", http.StatusInternalServerError)
+			return
+		}
+		data.Results = results
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "search.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// parseSearchParams reads q and limit (default 10, at most 50), writing a
+// 400 and returning false when limit is invalid.
+func parseSearchParams(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	limit := 10
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return "", 0, false
+		}
+		limit = min(parsed, 50)
+	}
+	return query, limit, true
+}
+
+// searchLinks finds links whose name or URL contains query, ranks them
+// with searchScore, and records the query in the search history. SQL only
+// narrows the candidates; the ordering is decided in Go.
+func (s *server) searchLinks(ctx context.Context, query string, limit int) ([]searchResult, error) {
+	pattern := escapeLike(query)
+	// Encrypted URLs cannot be matched in SQL, so every link is a
+	// candidate and the URL is matched after decrypting.
+	where := `l.name LIKE '%' || ? || '%' ESCAPE '\' OR l.url LIKE '%' || ? || '%' ESCAPE '\'`
+	args := []any{pattern, pattern, s.now().Unix(), maxSearchCandidates}
+	if s.cipher != nil {
+		where = `1`
+		args = []any{s.now().Unix(), -1}
+	}
+	// Expired links are hidden from search like they are on the dashboard.
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT l.id, l.name, l.url, c.name, l.last_status, l.last_checked, l.click_count
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id
+		 WHERE (`+where+`) AND (l.expires_at = 0 OR l.expires_at > ?)
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var results []searchResult
+	for rows.Next() {
+		var result searchResult
+		var lastChecked int64
+		if err := rows.Scan(&result.ID, &result.Link.Name, &result.Link.URL, &result.Link.CategoryName,
+			&result.Link.LastStatus, &lastChecked, &result.ClickCount); err != nil {
+			return nil, err
+		}
+		if err := s.cipher.openAll(&result.Link.URL); err != nil {
+			return nil, err
+		}
+		if lastChecked > 0 {
+			checked := time.Unix(lastChecked, 0).UTC()
+			result.Link.LastChecked = &checked
+		}
+		if s.cipher != nil && !containsFold(result.Link.Name, query) && !containsFold(result.Link.URL, query) {
+			continue
+		}
+		result.Score = searchScore(query, result.Link.Name, result.Link.URL, result.ClickCount)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	compare := s.compareNames
+	if compare == nil {
+		compare = newNameComparer("")
+	}
+	rankSearchResults(results, compare)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if err := s.recordSearch(ctx, query); err != nil {
+		log.Printf("record search: %v", err)
+	}
+	return results, nil
+}
+
+// rankSearchResults orders results by score, then by name with compare,
+// the same order as the dashboard's name sort, then id, so equal scores
+// come back in a stable order.
+func rankSearchResults(results []searchResult, compare func(a, b string) int) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if c := compare(a.Link.Name, b.Link.Name); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+}
+
+// Search score weights. A name match always outranks a URL match of the
+// same kind, and popularity only breaks near-ties: the click bonus is
+// capped below the gap between match kinds.
+const (
+	scorePrefix           = 3
+	scoreWordStart        = 2
+	scoreSubstring        = 1
+	scoreNameWeight       = 100
+	scoreURLWeight        = 40
+	maxClickBonus         = 15
+	clickBonusPerDoubling = 3
+)
+
+// searchScore rates how well a link matches query: by where the match
+// falls (prefix, then start of a word, then anywhere), whether it is in
+// the name or the URL, and how often the link has been opened.
+func searchScore(query string, name string, rawURL string, clicks int) float64 {
+	query = strings.ToLower(query)
+	score := float64(scoreNameWeight * matchStrength(strings.ToLower(name), query))
+	score += float64(scoreURLWeight * matchStrength(strings.ToLower(trimURLScheme(rawURL)), query))
+	if clicks > 0 {
+		score += min(math.Log2(float64(clicks)+1)*clickBonusPerDoubling, maxClickBonus)
+	}
+	return score
+}
+
+// matchStrength is 3 when text starts with query, 2 when a word inside
+// text does, 1 for any other occurrence, and 0 for none.
+func matchStrength(text string, query string) int {
+	if query == "" {
+		return 0
+	}
+	if strings.HasPrefix(text, query) {
+		return scorePrefix
+	}
+	best := 0
+	for offset := 0; ; {
+		idx := strings.Index(text[offset:], query)
+		if idx < 0 {
+			return best
+		}
+		at := offset + idx
+		previous, _ := utf8.DecodeLastRuneInString(text[:at])
+		if !unicode.IsLetter(previous) && !unicode.IsDigit(previous) {
+			return scoreWordStart
+		}
+		best = scoreSubstring
+		offset = at + 1
+	}
+}
+
+func containsFold(text string, substr string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(substr))
+}
+
+// trimURLScheme drops the scheme and a leading "www." so a query matching
+// the host counts as a prefix match.
+func trimURLScheme(rawURL string) string {
+	if _, rest, ok := strings.Cut(rawURL, "://"); ok {
+		rawURL = rest
+	}
+	return strings.TrimPrefix(rawURL, "www.")
+}
+
+// searchHistoryLimit caps how many searches are kept; older rows are
+// pruned on every insert.
+const searchHistoryLimit = 100
+
+// recordSearch appends query to the search history. Repeating the most
+// recent query only refreshes its timestamp instead of adding a row.
+func (s *server) recordSearch(ctx context.Context, query string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	var lastID int64
+	var lastQuery string
+	err = tx.QueryRowContext(ctx, `SELECT id, query FROM search_history ORDER BY id DESC LIMIT 1`).Scan(&lastID, &lastQuery)
+	switch {
+	case err == nil && lastQuery == query:
+		if _, err := tx.ExecContext(ctx, `UPDATE search_history SET searched_at = ? WHERE id = ?`, now, lastID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO search_history(query, searched_at) VALUES(?, ?)`, query, now); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM search_history WHERE id NOT IN (SELECT id FROM search_history ORDER BY id DESC LIMIT ?)`,
+		searchHistoryLimit,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type recentSearch struct {
+	Query      string    `json:"query"`
+	SearchedAt time.Time `json:"searched_at"`
+}
+
+// handleRecentSearches lists the most recent distinct search queries,
+// newest first.
+func (s *server) handleRecentSearches(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, searchHistoryLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT query, MAX(searched_at)
+		 FROM search_history
+		 GROUP BY query
+		 ORDER BY MAX(id) DESC
+		 LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		http.Error(w, "failed to load search history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	items := make([]recentSearch, 0, limit)
+	for rows.Next() {
+		var item recentSearch
+		var searchedAt int64
+		if err := rows.Scan(&item.Query, &searchedAt); err != nil {
+			http.Error(w, "failed to load search history", http.StatusInternalServerError)
+			return
+		}
+		item.SearchedAt = time.Unix(searchedAt, 0).UTC()
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to load search history", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally when
+// used with ESCAPE '\'.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// handleExportBookmarks serves every link as a Netscape bookmarks.html
+// file that browsers can import: one folder per category, nested in a
+// folder per panel when there is more than one panel.
+func (s *server) handleExportBookmarks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	doc, err := s.buildExport(ctx)
+	if err != nil {
+		http.Error(w, "failed to export bookmarks", http.StatusInternalServerError)
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	b.WriteString("<!-- This is an automatically generated file. It will be read and overwritten. DO NOT EDIT! -->\n")
+	b.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	b.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	stamp := strconv.FormatInt(doc.ExportedAt.Unix(), 10)
+	for _, p := range doc.Panels {
+		indent := "    "
+		if len(doc.Panels) > 1 {
+			fmt.Fprintf(&b, "%s<DT><H3 ADD_DATE=\"%s\">%s</H3>\n%s<DL><p>\n", indent, stamp, html.EscapeString(p.Name), indent)
+			indent += "    "
+		}
+		for _, c := range p.Categories {
+			fmt.Fprintf(&b, "%s<DT><H3 ADD_DATE=\"%s\">%s</H3>\n", indent, stamp, html.EscapeString(c.Name))
+			if c.Description != "" {
+				fmt.Fprintf(&b, "%s<DD>%s\n", indent, html.EscapeString(c.Description))
+			}
+			fmt.Fprintf(&b, "%s<DL><p>\n", indent)
+			for _, l := range c.Links {
+				fmt.Fprintf(&b, "%s    <DT><A HREF=\"%s\" ADD_DATE=\"%s\">%s</A>\n", indent, html.EscapeString(l.URL), stamp, html.EscapeString(l.Name))
+				if l.Description != "" {
+					fmt.Fprintf(&b, "%s    <DD>%s\n", indent, html.EscapeString(l.Description))
+				}
+			}
+			fmt.Fprintf(&b, "%s</DL><p>\n", indent)
+		}
+		if len(doc.Panels) > 1 {
+			fmt.Fprintf(&b, "    </DL><p>\n")
+		}
+	}
+	b.WriteString("</DL><p>\n")
+
+	name := backupFilePrefix + "bookmarks-" + time.Now().Format("20060102") + ".html"
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	_, _ = io.WriteString(w, b.String())
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Type     string        `xml:"type,attr,omitempty"`
+	URL      string        `xml:"url,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// handleExportOPML serves every link as an OPML 2.0 outline: one outline
+// per category holding its links, nested in an outline per panel when
+// there is more than one panel.
+func (s *server) handleExportOPML(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	doc, err := s.buildExport(ctx)
+	if err != nil {
+		http.Error(w, "failed to export opml", http.StatusInternalServerError)
+		return
+	}
+	opml := opmlDocument{Version: "2.0", Title: "Bookmarks", Created: doc.ExportedAt.Format(time.RFC1123Z)}
+	for _, p := range doc.Panels {
+		categories := make([]opmlOutline, 0, len(p.Categories))
+		for _, c := range p.Categories {
+			category := opmlOutline{Text: c.Name, Outlines: make([]opmlOutline, 0, len(c.Links))}
+			for _, l := range c.Links {
+				category.Outlines = append(category.Outlines, opmlOutline{Text: l.Name, Type: "link", URL: l.URL, HTMLURL: l.URL})
+			}
+			categories = append(categories, category)
+		}
+		if len(doc.Panels) > 1 {
+			opml.Body = append(opml.Body, opmlOutline{Text: p.Name, Outlines: categories})
+		} else {
+			opml.Body = append(opml.Body, categories...)
+		}
+	}
+	body, err := xml.MarshalIndent(opml, "", "  ")
+	if err != nil {
+		http.Error(w, "failed to export opml", http.StatusInternalServerError)
+		return
+	}
+
+	name := backupFilePrefix + "links-" + time.Now().Format("20060102") + ".opml"
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	_, _ = io.WriteString(w, xml.Header)
+	_, _ = w.Write(body)
+	_, _ = io.WriteString(w, "\n")
+}
+
+// csvHeader is the column layout of the CSV export, and the header the
+// CSV import requires.
+var csvHeader = []string{"category", "name", "url", "description"}
+
+// handleExportCSV serves one panel's links as CSV, one row per link,
+// sorted by category and then link name.
+func (s *server) handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	panelID, err := s.resolvePanelID(ctx, parseInt64OrZero(r.URL.Query().Get("panel_id")))
+	if err != nil {
+		http.Error(w, "failed to export csv", http.StatusInternalServerError)
+		return
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT c.name, l.name, l.url, l.description
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id
+		 WHERE c.panel_id = ?
+		 ORDER BY c.name COLLATE NOCASE ASC, l.name COLLATE NOCASE ASC, l.id ASC`,
+		panelID,
+	)
+	if err != nil {
+		http.Error(w, "failed to export csv", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	records := [][]string{csvHeader}
+	for rows.Next() {
+		record := make([]string, len(csvHeader))
+		if err := rows.Scan(&record[0], &record[1], &record[2], &record[3]); err != nil {
+			http.Error(w, "failed to export csv", http.StatusInternalServerError)
+			return
+		}
+		if err := s.cipher.openAll(&record[2], &record[3]); err != nil {
+			http.Error(w, "failed to export csv", http.StatusInternalServerError)
+			return
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to export csv", http.StatusInternalServerError)
+		return
+	}
+
+	name := backupFilePrefix + time.Now().Format("20060102") + ".csv"
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		log.Printf("export csv: %v", err)
+	}
+}
+
+// csvImportRow is one data row of a CSV import; Link has no category id
+// until the category is looked up or created by name.
+type csvImportRow struct {
+	Line     int
+	Category string
+	Link     linkInput
+}
+
+// parseImportCSV reads CSV in the export format and validates every row.
+// Invalid rows come back as failures; the error covers unreadable CSV or
+// a wrong header.
+func parseImportCSV(source io.Reader) ([]csvImportRow, []importFailure, error) {
+	reader := csv.NewReader(source)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("csv: required")
+	}
+	for i, column := range csvHeader {
+		if i >= len(records[0]) || !strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(records[0][i], "\ufeff")), column) {
+			return nil, nil, errors.New("csv: header must be " + strings.Join(csvHeader, ","))
+		}
+	}
+	if len(records[0]) != len(csvHeader) {
+		return nil, nil, errors.New("csv: header must be " + strings.Join(csvHeader, ","))
+	}
+
+	var rows []csvImportRow
+	var failures []importFailure
+	for i, record := range records[1:] {
+		row := csvImportRow{
+			Line:     i + 2,
+			Category: strings.TrimSpace(record[0]),
+			Link:     linkInput{Name: strings.TrimSpace(record[1]), URL: strings.TrimSpace(record[2]), Description: strings.TrimSpace(record[3])},
+		}
+		errs := row.Link.validateFields()
+		if row.Category == "" {
+			errs["category"] = "required"
+		} else if utf8.RuneCountInString(row.Category) > maxNameLength {
+			errs["category"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+		}
+		if len(errs) > 0 {
+			failures = append(failures, importFailure{Line: row.Line, Reason: errs.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, failures, nil
+}
+
+// handleImportCSV imports rows in the CSV export format into a panel,
+// creating missing categories by name. The CSV comes from the "csv" form
+// field or, with a text/csv content type, the raw body. Every row is
+// validated first and any error rejects the whole file. Like the URL
+// import it runs under importLimits rather than the usual request limits.
+func (s *server) handleImportCSV(w http.ResponseWriter, r *http.Request) {
+	var source io.Reader
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+		source = r.Body
+	} else {
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		source = strings.NewReader(r.FormValue("csv"))
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	if activePanelID == 0 {
+		activePanelID = parseInt64OrZero(r.URL.Query().Get("panel_id"))
+	}
+	skipExisting, err := parseSkipExisting(r.FormValue("skip_existing"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, failures, err := parseImportCSV(source)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, err)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(failures) > 0 {
+		http.Error(w, "invalid csv: "+joinImportFailures(failures), http.StatusBadRequest)
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, "csv: no rows to import", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	panelID, err := s.resolvePanelID(ctx, activePanelID)
+	if err != nil {
+		http.Error(w, "failed to import csv", http.StatusInternalServerError)
+		return
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		http.Error(w, "failed to import csv", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var saved *savedURLs
+	if skipExisting != "" {
+		if saved, err = s.loadSavedURLs(ctx, tx, skipExisting); err != nil {
+			http.Error(w, "failed to import csv", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Rows are resolved first so every category's capacity is checked
+	// before anything is inserted.
+	skipped := 0
+	inputs := make([]linkInput, 0, len(rows))
+	adding := map[int64]int{}
+	var categoryOrder []int64
+	for _, row := range rows {
+		categoryID, err := s.ensureCategoryTx(ctx, tx, panelID, row.Category)
+		if err != nil {
+			writeCapacityError(w, err, "failed to import csv")
+			return
+		}
+		if saved != nil && saved.seen(categoryID, row.Link.URL) {
+			skipped++
+			continue
+		}
+		in := row.Link
+		in.CategoryID = categoryID
+		inputs = append(inputs, in)
+		if adding[categoryID] == 0 {
+			categoryOrder = append(categoryOrder, categoryID)
+		}
+		adding[categoryID]++
+	}
+	for _, categoryID := range categoryOrder {
+		if err := s.checkCategoryCapacity(ctx, tx, categoryID, adding[categoryID]); err != nil {
+			writeCapacityError(w, err, "failed to import csv")
+			return
+		}
+	}
+	for _, in := range inputs {
+		if _, err := s.insertLink(ctx, tx, in); err != nil {
+			http.Error(w, "failed to import csv", http.StatusInternalServerError)
+			return
+		}
+	}
+	imported := len(inputs)
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "failed to import csv", http.StatusInternalServerError)
+		return
+	}
+	s.markChanged(changeEvent{Type: "links.imported"})
+	notice := fmt.Sprintf("Imported %d links.", imported)
+	if skipped > 0 {
+		notice += fmt.Sprintf(" Skipped %d already saved.", skipped)
+	}
+	s.renderDashboardNotice(w, panelID, notice)
+}
+
+// importPlan is what an import would do, as reported by
+// /actions/import/validate. Accepted is false when the real import would
+// be refused as a whole.
+type importPlan struct {
+	Format             string          `json:"format"`
+	Accepted           bool            `json:"accepted"`
+	PanelsToCreate     []string        `json:"panels_to_create"`
+	CategoriesToCreate []string        `json:"categories_to_create"`
+	LinksToAdd         int             `json:"links_to_add"`
+	DuplicatesToSkip   int             `json:"duplicates_to_skip"`
+	Failures           []importFailure `json:"failures"`
+}
+
+// refuse records a capacity error the real import would fail with as a
+// failure of the whole plan. Any other error is returned as is.
+func (p *importPlan) refuse(err error) error {
+	var full categoryFullError
+	var limit categoryLimitError
+	if !errors.As(err, &full) && !errors.As(err, &limit) {
+		return err
+	}
+	p.Failures = append(p.Failures, importFailure{Reason: err.Error()})
+	p.Accepted = false
+	return nil
+}
+
+// handleValidateImport dry-runs an import and answers with its
+// importPlan without writing anything. It takes the same fields as the
+// real importers, picked by which one is present: urls (with category_id),
+// csv (with active_panel_id), or json, an export document as used by the
+// import command. A text/csv or application/json body stands for the csv
+// or json field. skip_existing works as it does for the real imports.
+func (s *server) handleValidateImport(w http.ResponseWriter, r *http.Request) {
+	var format string
+	var source io.Reader
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "text/csv":
+		format, source = "csv", r.Body
+	case "application/json":
+		format, source = "json", r.Body
+	default:
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		for _, field := range []string{"urls", "csv", "json"} {
+			if _, ok := r.PostForm[field]; ok {
+				format, source = field, strings.NewReader(r.PostForm.Get(field))
+				break
+			}
+		}
+	}
+	if format == "" {
+		http.Error(w, "one of urls, csv, or json is required", http.StatusBadRequest)
+		return
+	}
+	skipExisting, err := parseSkipExisting(r.FormValue("skip_existing"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	plan := importPlan{Format: format, PanelsToCreate: []string{}, CategoriesToCreate: []string{}, Failures: []importFailure{}}
+	switch format {
+	case "urls":
+		err = s.planURLImport(ctx, &plan, source, parseInt64OrZero(r.FormValue("category_id")), skipExisting)
+	case "csv":
+		err = s.planCSVImport(ctx, &plan, source, parseInt64OrZero(r.FormValue("active_panel_id")), skipExisting)
+	case "json":
+		var doc exportDocument
+		if err = json.NewDecoder(source).Decode(&doc); err != nil {
+			err = importInputError{fmt.Errorf("json: %w", err)}
+			break
+		}
+		err = s.planDocumentImport(ctx, &plan, doc)
+	}
+	if err != nil {
+		var invalid importInputError
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeBodyError(w, err)
+		case errors.As(err, &invalid):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "failed to validate import", http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// importInputError is input a real import would refuse with a 400.
+type importInputError struct{ err error }
+
+func (e importInputError) Error() string { return e.err.Error() }
+
+func (s *server) planURLImport(ctx context.Context, plan *importPlan, source io.Reader, categoryID int64, skipExisting string) error {
+	text, err := io.ReadAll(source)
+	if err != nil {
+		return err
+	}
+	inputs, failures, err := parseImportURLs(string(text), categoryID)
+	if err != nil {
+		return importInputError{err}
+	}
+	plan.Failures = append(plan.Failures, failures...)
+	if len(inputs) > 0 {
+		if errs := s.validateLinkInput(ctx, inputs[0]); errs["category_id"] != "" {
+			return importInputError{errors.New("category_id: " + errs["category_id"])}
+		}
+	}
+	inputs, plan.DuplicatesToSkip, err = s.skipSavedURLs(ctx, inputs, skipExisting)
+	if err != nil {
+		return err
+	}
+	plan.LinksToAdd = len(inputs)
+	plan.Accepted = len(inputs)+plan.DuplicatesToSkip > 0
+	if err := s.checkCategoryCapacity(ctx, s.db, categoryID, len(inputs)); err != nil {
+		return plan.refuse(err)
+	}
+	return nil
+}
+
+// planCSVImport mirrors handleImportCSV. Categories that do not exist yet
+// get made-up negative ids so skip_existing still catches repeats within
+// the file.
+func (s *server) planCSVImport(ctx context.Context, plan *importPlan, source io.Reader, activePanelID int64, skipExisting string) error {
+	rows, failures, err := parseImportCSV(source)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return importInputError{err}
+	}
+	plan.Failures = append(plan.Failures, failures...)
+	panelID, err := s.resolvePanelID(ctx, activePanelID)
+	if err != nil {
+		return err
+	}
+	categoryIDs, err := s.categoryIDsByName(ctx, panelID)
+	if err != nil {
+		return err
+	}
+	var saved *savedURLs
+	if skipExisting != "" {
+		if saved, err = s.loadSavedURLs(ctx, s.db, skipExisting); err != nil {
+			return err
+		}
+	}
+	adding := map[int64]int{}
+	var categoryOrder []int64
+	for _, row := range rows {
+		categoryID, ok := categoryIDs[row.Category]
+		if !ok {
+			categoryID = -int64(len(plan.CategoriesToCreate) + 1)
+			categoryIDs[row.Category] = categoryID
+			plan.CategoriesToCreate = append(plan.CategoriesToCreate, row.Category)
+		}
+		if saved != nil && saved.seen(categoryID, row.Link.URL) {
+			plan.DuplicatesToSkip++
+			continue
+		}
+		plan.LinksToAdd++
+		if adding[categoryID] == 0 {
+			categoryOrder = append(categoryOrder, categoryID)
+		}
+		adding[categoryID]++
+	}
+	plan.Accepted = len(failures) == 0 && len(rows) > 0
+	// New categories have made-up ids with no links, so they are checked
+	// against the cap alone.
+	for _, categoryID := range categoryOrder {
+		if err := s.checkCategoryCapacity(ctx, s.db, categoryID, adding[categoryID]); err != nil {
+			if err := plan.refuse(err); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.checkCategoryLimit(ctx, s.db, len(plan.CategoriesToCreate)); err != nil {
+		return plan.refuse(err)
+	}
+	return nil
+}
+
+// planDocumentImport mirrors importDocument, listing every problem
+// rather than stopping at the first. Categories are reported as
+// "panel/category".
+func (s *server) planDocumentImport(ctx context.Context, plan *importPlan, doc exportDocument) error {
+	panelIDs := make(map[string]int64)
+	panels, err := s.loadPanels(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range panels {
+		panelIDs[p.Name] = p.ID
+	}
+	for _, p := range doc.Panels {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			plan.Failures = append(plan.Failures, importFailure{Reason: "panel with empty name"})
+			continue
+		}
+		categoryIDs := map[string]int64{}
+		adding := map[string]int{}
+		if panelID, ok := panelIDs[name]; ok {
+			if categoryIDs, err = s.categoryIDsByName(ctx, panelID); err != nil {
+				return err
+			}
+		} else if !slices.Contains(plan.PanelsToCreate, name) {
+			plan.PanelsToCreate = append(plan.PanelsToCreate, name)
+		}
+		for _, c := range p.Categories {
+			categoryName := strings.TrimSpace(c.Name)
+			if categoryName == "" {
+				plan.Failures = append(plan.Failures, importFailure{Reason: fmt.Sprintf("category with empty name in %s", name)})
+				continue
+			}
+			if _, ok := categoryIDs[categoryName]; !ok {
+				categoryIDs[categoryName] = 0
+				plan.CategoriesToCreate = append(plan.CategoriesToCreate, name+"/"+categoryName)
+			}
+			for _, l := range c.Links {
+				if errs := l.input(0).validateFields(); len(errs) > 0 {
+					plan.Failures = append(plan.Failures, importFailure{Reason: fmt.Sprintf("link %q in %s/%s: %s", l.Name, name, c.Name, errs.Error())})
+					continue
+				}
+				plan.LinksToAdd++
+			}
+			// By the time the real import checks a repeated category, the
+			// links of its earlier entries are in, so they add up here.
+			adding[categoryName] += len(c.Links)
+			if err := s.checkCategoryCapacity(ctx, s.db, categoryIDs[categoryName], adding[categoryName]); err != nil {
+				var full categoryFullError
+				if !errors.As(err, &full) {
+					return err
+				}
+				plan.Failures = append(plan.Failures, importFailure{Reason: fmt.Sprintf("%s/%s: %s", name, categoryName, full.Error())})
+			}
+		}
+	}
+	if err := s.checkCategoryLimit(ctx, s.db, len(plan.CategoriesToCreate)); err != nil {
+		if err := plan.refuse(err); err != nil {
+			return err
+		}
+	}
+	plan.Accepted = len(plan.Failures) == 0
+	return nil
+}
+
+// categoryIDsByName maps the panel's category names to their ids.
+func (s *server) categoryIDsByName(ctx context.Context, panelID int64) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name FROM categories WHERE panel_id = ?`, panelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// handleBackup streams a consistent snapshot of the database. VACUUM INTO
+// writes the copy to a scratch directory that is always removed afterwards.
+func (s *server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	dir, err := os.MkdirTemp("", "personal_dash-backup-")
+	if err != nil {
+		http.Error(w, "failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(r.Context(), backupTimeout)
+	defer cancel()
+
+	snapshot := filepath.Join(dir, "backup.db")
+	if err := vacuumInto(ctx, s.db, snapshot); err != nil {
+		log.Printf("backup: %v", err)
+		http.Error(w, "failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(snapshot)
+	if err != nil {
+		http.Error(w, "failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to create backup", http.StatusInternalServerError)
+		return
+	}
+
+	name := backupFilePrefix + time.Now().Format("20060102-150405") + ".db"
+	w.Header().Set("Content-Type", "application/x-sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("backup: stream: %v", err)
+	}
+}
+
+const backupFilePrefix = "personal_dash-"
+
+// runScheduledBackups snapshots the database into dir once at startup and
+// then every interval until ctx is cancelled, pruning all but the newest
+// keep snapshots (keep == 0 retains everything).
+func (s *server) runScheduledBackups(ctx context.Context, dir string, interval time.Duration, keep int) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("scheduled backup: create %s: %v", dir, err)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.runScheduledBackup(ctx, dir, keep)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *server) runScheduledBackup(parent context.Context, dir string, keep int) {
+	ctx, cancel := context.WithTimeout(parent, backupTimeout)
+	defer cancel()
+
+	target := filepath.Join(dir, backupFilePrefix+time.Now().Format("20060102-150405")+".db")
+	if err := vacuumInto(ctx, s.db, target); err != nil {
+		log.Printf("scheduled backup: %v", err)
+		return
+	}
+	log.Printf("scheduled backup written to %s", target)
+	if err := pruneBackups(dir, keep); err != nil {
+		log.Printf("scheduled backup: prune: %v", err)
+	}
+}
+
+func pruneBackups(dir string, keep int) error {
+	if keep == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, ".db") {
+			names = append(names, name)
+		}
+	}
+	// Timestamps in the names sort chronologically.
+	sort.Strings(names)
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// expirySweepInterval is how often EXPIRED_LINKS=delete removes links
+// past their expiry. They are hidden from the dashboard in between.
+const expirySweepInterval = 10 * time.Minute
+
+// runExpirySweeps deletes expired links once at startup and then every
+// interval until ctx is cancelled.
+func (s *server) runExpirySweeps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.sweepExpiredLinks(ctx); err != nil {
+			log.Printf("expiry sweep: %v", err)
+		} else if n > 0 {
+			log.Printf("expiry sweep: deleted %d expired links", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepExpiredLinks deletes the links whose expires_at has passed,
+// recording each in the audit log, and returns how many it removed.
+func (s *server) sweepExpiredLinks(parent context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(parent, requestTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type expiredLink struct {
+		id, categoryID int64
+		name           string
+	}
+	now := time.Now().Unix()
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, name, category_id FROM links WHERE expires_at > 0 AND expires_at <= ?`, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var expired []expiredLink
+	for rows.Next() {
+		var link expiredLink
+		if err := rows.Scan(&link.id, &link.name, &link.categoryID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, link)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	for _, link := range expired {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE id = ?`, link.id); err != nil {
+			return 0, err
+		}
+		details := map[string]any{"name": link.name, "category_id": link.categoryID, "reason": "expired"}
+		if err := recordAudit(ctx, tx, "delete", "link", link.id, details); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.markChanged(changeEvent{Type: "links.expired"})
+	return len(expired), nil
+}
+
+// gitBackupFile is the export file kept under version control in
+// GIT_BACKUP_DIR.
+const gitBackupFile = "personal_dash.json"
+
+// runGitBackups commits a JSON export to the git work tree dir once at
+// startup and then every interval until ctx is cancelled.
+func (s *server) runGitBackups(ctx context.Context, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.runGitBackup(ctx, dir); err != nil {
+			log.Printf("git backup: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runGitBackup writes the export to gitBackupFile and commits it. An
+// unchanged dashboard keeps the previous exported_at, so the file stays
+// byte-identical and there is nothing to commit.
+func (s *server) runGitBackup(parent context.Context, dir string) error {
+	ctx, cancel := context.WithTimeout(parent, backupTimeout)
+	defer cancel()
+
+	doc, err := s.buildExport(ctx)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, gitBackupFile)
+	previous, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if !sameExport(doc, previous) {
+		body, err := marshalExport(doc)
+		if err != nil {
+			return err
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, body, 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+
+	if _, err := runGit(ctx, dir, "add", "--", gitBackupFile); err != nil {
+		return err
+	}
+	status, err := runGit(ctx, dir, "status", "--porcelain", "--", gitBackupFile)
+	if err != nil {
+		return err
+	}
+	if status == "" {
+		log.Printf("git backup: nothing to commit in %s", dir)
+		return nil
+	}
+	message := "personal_dash export " + time.Now().UTC().Format(time.RFC3339)
+	if _, err := runGit(ctx, dir, "commit", "--quiet", "-m", message, "--", gitBackupFile); err != nil {
+		return err
+	}
+	hash, err := runGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	log.Printf("git backup: committed %s in %s", hash, dir)
+	return nil
+}
+
+// sameExport reports whether previous holds doc apart from exported_at.
+func sameExport(doc exportDocument, previous []byte) bool {
+	var prev exportDocument
+	if err := json.Unmarshal(previous, &prev); err != nil {
+		return false
+	}
+	doc.ExportedAt = prev.ExportedAt
+	body, err := marshalExport(doc)
+	return err == nil && bytes.Equal(body, previous)
+}
+
+func marshalExport(doc exportDocument) ([]byte, error) {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// runGit runs git in dir and returns its trimmed stdout. Failures include
+// git's own error output. Cancelling ctx kills the process.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+type foreignKeyViolation struct {
+	Table  string `json:"table"`
+	RowID  int64  `json:"rowid"`
+	Parent string `json:"parent"`
+	FKID   int64  `json:"fkid"`
+}
+
+type integrityReport struct {
+	OK                   bool                  `json:"ok"`
+	Integrity            []string              `json:"integrity"`
+	ForeignKeyViolations []foreignKeyViolation `json:"foreign_key_violations"`
+}
+
+// handleIntegrity runs SQLite's integrity and foreign key checks.
+func (s *server) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), integrityTimeout)
+	defer cancel()
+
+	report := integrityReport{Integrity: []string{}, ForeignKeyViolations: []foreignKeyViolation{}}
+	rows, err := s.db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		http.Error(w, "failed to check integrity", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			http.Error(w, "failed to check integrity", http.StatusInternalServerError)
+			return
+		}
+		report.Integrity = append(report.Integrity, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to check integrity", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err = s.db.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		http.Error(w, "failed to check foreign keys", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var v foreignKeyViolation
+		var rowID sql.NullInt64
+		if err := rows.Scan(&v.Table, &rowID, &v.Parent, &v.FKID); err != nil {
+			rows.Close()
+			http.Error(w, "failed to check foreign keys", http.StatusInternalServerError)
+			return
+		}
+		v.RowID = rowID.Int64
+		report.ForeignKeyViolations = append(report.ForeignKeyViolations, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, "failed to check foreign keys", http.StatusInternalServerError)
+		return
+	}
+
+	report.OK = len(report.Integrity) == 1 && report.Integrity[0] == "ok" && len(report.ForeignKeyViolations) == 0
+	writeJSON(w, http.StatusOK, report)
+}
+
+type optimizeReport struct {
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+// handleOptimize refreshes planner statistics and rebuilds the database
+// file to drop free pages. VACUUM cannot run inside a transaction, so it
+// runs straight on the pool; with its single connection every other
+// request waits until it is done, which gives VACUUM the exclusive access
+// it needs.
+func (s *server) handleOptimize(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), maintenanceTimeout)
+	defer cancel()
+
+	var report optimizeReport
+	var err error
+	if report.BytesBefore, err = databaseSize(ctx, s.db); err != nil {
+		http.Error(w, "failed to optimize database", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `PRAGMA optimize`); err != nil {
+		log.Printf("optimize: %v", err)
+		http.Error(w, "failed to optimize database", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+		log.Printf("optimize: vacuum: %v", err)
+		http.Error(w, "failed to optimize database", http.StatusInternalServerError)
+		return
+	}
+	if report.BytesAfter, err = databaseSize(ctx, s.db); err != nil {
+		http.Error(w, "failed to optimize database", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// databaseSize is the size of the main database file in bytes, computed
+// from its page count so it also works for DSNs that are not plain paths.
+func databaseSize(ctx context.Context, db *sql.DB) (int64, error) {
+	var pages, pageSize int64
+	if err := db.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRowContext(ctx, `PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
+}
+
+// Defaults and bounds for PIN.
+const (
+	defaultPinIdleTimeout = 5 * time.Minute
+	minPinLength          = 4
+	// pinMaxFailures wrong PINs in a row from one client IP block that
+	// client's attempts for pinFailureBlock, which keeps a short PIN from
+	// being guessed without letting anyone lock the owner out.
+	pinMaxFailures  = 5
+	pinFailureBlock = time.Minute
+	// pinSessionCookie carries the session from POST /actions/unlock; API
+	// clients may send the token in pinSessionHeader instead.
+	pinSessionCookie = "pd_session"
+	pinSessionHeader = "X-Session-Token"
+)
+
+// pinLock is the kiosk lock behind PIN. Reads stay open; requests to
+// /actions/ need the PIN itself or a session from POST /actions/unlock.
+// Sessions live in memory and expire after idle time without a request,
+// so a restart locks everything again.
+type pinLock struct {
+	pin  string
+	idle time.Duration
+
+	// proxies decides which client IP wrong PINs are counted against.
+	proxies trustedProxies
+
+	mu       sync.Mutex
+	sessions map[string]time.Time    // token to last use
+	attempts map[string]*pinAttempts // client IP to its wrong PINs
+}
+
+// pinAttempts counts one client's wrong PINs in a row.
+type pinAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// newPinLock returns nil when pin is empty, which leaves actions open.
+func newPinLock(pin string, idle time.Duration, proxies trustedProxies) *pinLock {
+	if pin == "" {
+		return nil
+	}
+	return &pinLock{
+		pin:      pin,
+		idle:     idle,
+		proxies:  proxies,
+		sessions: make(map[string]time.Time),
+		attempts: make(map[string]*pinAttempts),
+	}
+}
+
+// errPinBlocked is returned while too many wrong PINs have been tried.
+var errPinBlocked = errors.New("too many wrong PINs; try again in a minute")
+
+// check compares given against the PIN, counting failures toward the
+// temporary block of client, the requester's IP.
+func (l *pinLock) check(client string, given string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	attempts := l.attempts[client]
+	if attempts != nil && now.Before(attempts.blockedUntil) {
+		return false, errPinBlocked
+	}
+	if subtle.ConstantTimeCompare([]byte(given), []byte(l.pin)) == 1 {
+		delete(l.attempts, client)
+		return true, nil
+	}
+	if attempts == nil {
+		// Clients that stopped guessing are forgotten, so the map only
+		// holds recent ones.
+		for ip, a := range l.attempts {
+			if now.Sub(a.lastFailure) > pinFailureBlock && !now.Before(a.blockedUntil) {
+				delete(l.attempts, ip)
+			}
+		}
+		attempts = &pinAttempts{}
+		l.attempts[client] = attempts
+	}
+	attempts.failures++
+	attempts.lastFailure = now
+	if attempts.failures >= pinMaxFailures {
+		attempts.failures = 0
+		attempts.blockedUntil = now.Add(pinFailureBlock)
+	}
+	return false, nil
+}
+
+// open starts a session and returns its token, sweeping expired ones.
+func (l *pinLock) open() (string, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for t, last := range l.sessions {
+		if now.Sub(last) > l.idle {
+			delete(l.sessions, t)
+		}
+	}
+	l.sessions[token] = now
+	return token, nil
+}
+
+// touch reports whether token is a live session and restarts its idle
+// timer.
+func (l *pinLock) touch(token string) bool {
+	if token == "" {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last, ok := l.sessions[token]
+	if !ok {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(last) > l.idle {
+		delete(l.sessions, token)
+		return false
+	}
+	l.sessions[token] = now
+	return true
+}
+
+func (l *pinLock) close(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, token)
+}
+
+// sessionToken reads the session from the cookie or, failing that, the
+// header.
+func sessionToken(r *http.Request) string {
+	if cookie, err := r.Cookie(pinSessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	return r.Header.Get(pinSessionHeader)
+}
+
+// middleware rejects /actions/ requests that carry neither a live session
+// nor the PIN in the X-Pin header. The PIN is not read from the body so
+// each route keeps its own body limits, nor from the query string, which
+// ends up in proxy logs and browser history. Unlocking and locking are
+// always let through.
+func (l *pinLock) middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/actions/") || r.URL.Path == "/actions/unlock" || r.URL.Path == "/actions/lock" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if l.touch(sessionToken(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if given := r.Header.Get("X-Pin"); given != "" {
+			ok, err := l.check(l.proxies.clientIP(r), given)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, "locked; unlock with the PIN", http.StatusUnauthorized)
+	})
+}
+
+// handleUnlock trades the pin field for a session cookie. JSON callers
+// also get the token for the X-Session-Token header.
+func (s *server) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	var in struct {
+		Pin           string `json:"pin"`
+		ActivePanelID int64  `json:"active_panel_id"`
+	}
+	if isJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+	} else {
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		// PostFormValue skips the query string, which would leak the PIN.
+		in.Pin = r.PostFormValue("pin")
+		in.ActivePanelID = parseInt64OrZero(r.FormValue("active_panel_id"))
+	}
+	if s.pin == nil {
+		http.Error(w, "no PIN is configured", http.StatusNotFound)
+		return
+	}
+	ok, err := s.pin.check(s.pin.proxies.clientIP(r), strings.TrimSpace(in.Pin))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if !ok {
+		writeFieldErrors(w, r, http.StatusUnauthorized, fieldErrors{"pin": "incorrect"})
+		return
+	}
+	token, err := s.pin.open()
+	if err != nil {
+		http.Error(w, "failed to unlock", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     pinSessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, map[string]any{"token": token, "idle_timeout_seconds": int(s.pin.idle.Seconds())})
+		return
+	}
+	s.renderDashboard(w, in.ActivePanelID)
+}
+
+// handleLock ends the caller's session before the idle timeout does.
+func (s *server) handleLock(w http.ResponseWriter, r *http.Request) {
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	if s.pin != nil {
+		s.pin.close(sessionToken(r))
+	}
+	http.SetCookie(w, &http.Cookie{Name: pinSessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	if isJSONRequest(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+// requireAdminToken guards admin-only endpoints with a bearer token. They
+// stay disabled until ADMIN_TOKEN is configured.
+func requireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled; set ADMIN_TOKEN to enable them", http.StatusForbidden)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="personal_dash"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdminLogin guards read-only admin pages. They are open while
+// ADMIN_TOKEN is unset; once it is set they take the token as a bearer
+// token or as the password of HTTP basic auth, so a browser can prompt for
+// it.
+func requireAdminLogin(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			next(w, r)
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="personal_dash", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminTopLinksLimit is how many of the most opened links /admin lists.
+const adminTopLinksLimit = 10
+
+type adminData struct {
+	Categories    int
+	Links         int
+	BrokenLinks   int
+	DatabaseBytes int64
+	TopLinks      []adminTopLink
+}
+
+type adminTopLink struct {
+	ID           int64
+	Name         string
+	URL          string
+	CategoryName string
+	ClickCount   int
+}
+
+// DatabaseSize formats DatabaseBytes for display.
+func (d adminData) DatabaseSize() string {
+	const unit = 1024
+	if d.DatabaseBytes < unit {
+		return fmt.Sprintf("%d B", d.DatabaseBytes)
+	}
+	value := float64(d.DatabaseBytes)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+// handleAdmin renders the read-only operational overview. It runs its own
+// counting queries across every panel instead of building dashboard data.
+func (s *server) handleAdmin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	data, err := s.loadAdminData(ctx)
+	if err != nil {
+		http.Error(w, "failed to load admin stats", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.templates.ExecuteTemplate(w, "admin.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+func (s *server) loadAdminData(ctx context.Context) (adminData, error) {
+	data := adminData{TopLinks: []adminTopLink{}}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&data.Categories); err != nil {
+		return adminData{}, err
+	}
+	// A link counts as broken once a health check has run and did not
+	// get a 2xx or 3xx answer, matching dashboardLink.Healthy.
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*),
+		        COALESCE(SUM(CASE WHEN last_checked > 0 AND (last_status < 200 OR last_status >= 400) THEN 1 ELSE 0 END), 0)
+		 FROM links`,
+	).Scan(&data.Links, &data.BrokenLinks); err != nil {
+		return adminData{}, err
+	}
+	size, err := databaseSize(ctx, s.db)
+	if err != nil {
+		return adminData{}, err
+	}
+	data.DatabaseBytes = size
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT l.id, l.name, l.url, c.name, l.click_count
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id
+		 WHERE l.click_count > 0
+		 ORDER BY l.click_count DESC, l.id ASC
+		 LIMIT ?`,
+		adminTopLinksLimit,
+	)
+	if err != nil {
+		return adminData{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link adminTopLink
+		if err := rows.Scan(&link.ID, &link.Name, &link.URL, &link.CategoryName, &link.ClickCount); err != nil {
+			return adminData{}, err
+		}
+		if err := s.cipher.openAll(&link.URL); err != nil {
+			return adminData{}, err
+		}
+		data.TopLinks = append(data.TopLinks, link)
+	}
+	return data, rows.Err()
+}
+
+// vacuumInto writes a transactionally consistent copy of the database to
+// path, which must not exist yet.
+func vacuumInto(ctx context.Context, db *sql.DB, path string) error {
+	_, err := db.ExecContext(ctx, `VACUUM INTO ?`, path)
+	return err
+}
+
+// templateFuncs are the helpers every template can call.
+var templateFuncs = template.FuncMap{
+	"host":     displayHost,
+	"truncate": truncateText,
+}
+
+// displayHost shortens a URL to its host without "www.", e.g.
+// "https://www.example.com/a?b" becomes "example.com". Values without a
+// host come back unchanged.
+func displayHost(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Hostname() == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// truncateText cuts text to at most max characters, ending in "…" when
+// anything was dropped. The argument order suits pipelines:
+// {{.URL | truncate 40}}.
+func truncateText(max int, text string) string {
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	if max == 1 {
+		return "…"
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// checkTemplates renders every page template once at startup, both empty
+// and with one of each item, so field typos fail the boot instead of
+// turning into 500s. html/template only resolves fields at execution time.
+func checkTemplates(tpl *template.Template) error {
+	link := dashboardLink{ID: "1", CategoryID: "1", CategoryName: "Sample", Name: "Sample", URL: "https://example.com", TargetBlank: true, LastStatus: 200, LastCheckedAt: time.Unix(1, 0), IconVersion: 1, VisibleFrom: "09:00", VisibleTo: "17:00", Hotkey: "g h", ExpiresAt: time.Unix(1, 0)}
+	link.IconDataURI = monogramDataURI(link.Name, link.URL)
+	disabled := link
+	disabled.Disabled = true
+	samples := map[string][]any{
+		"dashboard.html": {
+			dashboardData{},
+			dashboardData{
+				Panels:          []dashboardPanel{{ID: "1", Name: "Sample"}},
+				ActivePanel:     "1",
+				Categories:      []dashboardCategory{{ID: "1", Name: "Sample", Description: "Sample", Links: []dashboardLink{link, disabled}}},
+				QuickLinks:      []dashboardLink{link, disabled},
+				FavoritesBar:    []dashboardLink{link, disabled},
+				Presets:         []dashboardPreset{{ID: "1", Name: "Sample"}},
+				FormPanelID:     "1",
+				Sort:            defaultLinkSort,
+				View:            defaultDashboardView,
+				Notice:          "Sample",
+				SiteTitle:       defaultSiteTitle,
+				SiteSubtitle:    defaultSiteSubtitle,
+				AccentColor:     "#3d6aff",
+				BackgroundColor: "#415ebf",
+				PinRequired:     true,
+			},
+			dashboardData{
+				Categories: []dashboardCategory{{ID: "1", Name: "Sample", Links: []dashboardLink{link, disabled}}},
+				View:       "compact",
+			},
+		},
+		"stale.html":    {staleData{}, staleData{Days: 90, Links: []dashboardLink{link}}},
+		"expiring.html": {expiringData{}, expiringData{Days: 7, Links: []dashboardLink{link}}},
+		"category-column": {
+			categoryView{},
+			categoryView{
+				Category:    dashboardCategory{ID: "1", Name: "Sample", Description: "Sample", Collapsed: true, Links: []dashboardLink{link}},
+				FormPanelID: "1",
+				Categories:  []dashboardCategory{{ID: "1", Name: "Sample"}},
+			},
+		},
+		"onboarding":   {dashboardData{}, dashboardData{FormPanelID: "1", ArchivedCategories: []dashboardCategory{{ID: "1", Name: "Sample", Archived: true}}}},
+		"confirm.html": {confirmData{}, confirmData{ID: 1, Name: "Sample", URL: "https://example.com"}},
+		"share.html":   {shareData{}, shareData{Name: "Sample", Description: "Sample", Links: []dashboardLink{link}}},
+		"capture.html": {captureData{}, captureData{ID: 1, Name: "Sample", URL: "https://example.com", Category: inboxName}},
+		"admin.html": {
+			adminData{},
+			adminData{Categories: 1, Links: 1, BrokenLinks: 1, DatabaseBytes: 4096, TopLinks: []adminTopLink{{ID: 1, Name: "Sample", URL: "https://example.com", CategoryName: "Sample", ClickCount: 1}}},
+		},
+		"search.html": {
+			searchData{},
+			searchData{Query: "sam", Results: []searchResult{{ID: 1, Link: quickOpenItem{Name: "Sample", URL: "https://example.com", CategoryName: "Sample"}}}},
+		},
+		"archived.html": {
+			archivedData{},
+			archivedData{FormPanelID: "1", Categories: []dashboardCategory{{ID: "1", Name: "Sample", Archived: true, Links: []dashboardLink{link}}}},
+		},
+		"link-form": {
+			linkFormView{},
+			linkFormView{
+				FormPanelID: "1",
+				Categories:  []dashboardCategory{{ID: "1", Name: "Sample"}},
+				Values:      linkFormValues{Name: "Sample", URL: "https://example.com", CategoryID: "1", TargetBlank: true},
+				Errors:      fieldErrors{"name": "required", "url": "required", "description": "required", "category_id": "required"},
+			},
+		},
+	}
+	for name, items := range samples {
+		for _, data := range items {
+			if err := tpl.ExecuteTemplate(io.Discard, name, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) renderDashboard(w http.ResponseWriter, requestedPanelID int64) {
+	s.renderSortedDashboard(w, requestedPanelID, defaultLinkSort)
+}
+
+func (s *server) renderSortedDashboard(w http.ResponseWriter, requestedPanelID int64, sortKey string) {
+	s.renderDashboardView(w, requestedPanelID, sortKey, defaultDashboardView, "")
+}
+
+// renderDashboardNotice renders the dashboard with a message above it.
+func (s *server) renderDashboardNotice(w http.ResponseWriter, requestedPanelID int64, notice string) {
+	s.renderDashboardView(w, requestedPanelID, defaultLinkSort, defaultDashboardView, notice)
+}
+
+func (s *server) renderDashboardView(w http.ResponseWriter, requestedPanelID int64, sortKey string, view string, notice string) {
+	data, err := s.getDashboardData(context.Background(), requestedPanelID, sortKey)
+	if err != nil {
+		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+	data, _ = data.visibleAt(s.now(), s.collapseThreshold)
+	data.View = view
+	data.Notice = notice
+	s.writeDashboard(w, data)
+}
+
+// handleCategoryPartial renders a single category's block from the
+// detailed view, for swapping one section instead of the whole dashboard.
+// Archived categories are not on the dashboard and get a 404 too.
+func (s *server) handleCategoryPartial(w http.ResponseWriter, r *http.Request) {
+	categoryID := parseInt64OrZero(r.PathValue("id"))
+	if categoryID == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	sortKey := strings.TrimSpace(r.URL.Query().Get("sort"))
+	if sortKey == "" {
+		sortKey = defaultLinkSort
+	}
+	if _, ok := linkSortOrders[sortKey]; !ok {
+		http.Error(w, "sort must be one of position, name, recent, popular", http.StatusBadRequest)
+		return
+	}
+	s.renderCategory(w, r, categoryID, sortKey)
+}
+
+// renderCategoryOrDashboard answers an action on categoryID with just
+// that category when the htmx request targets its block (HX-Target
+// "category-<id>"), and with the whole dashboard otherwise.
+func (s *server) renderCategoryOrDashboard(w http.ResponseWriter, r *http.Request, activePanelID int64, categoryID int64) {
+	if r.Header.Get("HX-Target") == "category-"+strconv.FormatInt(categoryID, 10) {
+		s.renderCategory(w, r, categoryID, defaultLinkSort)
+		return
+	}
+	s.renderDashboard(w, activePanelID)
+}
+
+func (s *server) renderCategory(w http.ResponseWriter, r *http.Request, categoryID int64, sortKey string) {
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	var panelID int64
+	if err := s.db.QueryRowContext(ctx, `SELECT panel_id FROM categories WHERE id = ?`, categoryID).Scan(&panelID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "category not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load category", http.StatusInternalServerError)
+		return
+	}
+	data, err := s.getDashboardData(ctx, panelID, sortKey)
+	if err != nil {
+		http.Error(w, "failed to load category", http.StatusInternalServerError)
+		return
+	}
+	data, _ = data.visibleAt(s.now(), s.collapseThreshold)
+	id := strconv.FormatInt(categoryID, 10)
+	for _, c := range data.Categories {
+		if c.ID != id {
+			continue
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := s.templates.ExecuteTemplate(w, "category-column", data.CategoryView(c)); err != nil {
+			http.Error(w, "failed to render template", http.StatusInternalServerError)
+		}
 		return
 	}
-	catIDs := make([]int64, 0, 16)
-	for catRows.Next() {
-		var id int64
-		if err := catRows.Scan(&id); err != nil {
-			catRows.Close()
-			http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-			return
+	http.Error(w, "category not found", http.StatusNotFound)
+}
+
+func (s *server) writeDashboard(w http.ResponseWriter, data dashboardData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, "dashboard.html", data); err != nil {
+		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	}
+}
+
+// visibleAt returns a copy of the dashboard without the links that are
+// outside their visibility window or expired at now, leaving the cached
+// data alone.
+// Categories still on collapseAuto are collapsed here, once their visible
+// links are known, if they show more than collapseThreshold links. The
+// second result fingerprints the hidden links and is 0 when none are.
+func (d dashboardData) visibleAt(now time.Time, collapseThreshold int) (dashboardData, uint64) {
+	hash := fnv.New64a()
+	hidden := false
+	keep := func(links []dashboardLink) []dashboardLink {
+		kept := make([]dashboardLink, 0, len(links))
+		for _, link := range links {
+			if visibleAt(link.VisibleFrom, link.VisibleTo, now) && !link.ExpiredAt(now) {
+				kept = append(kept, link)
+				continue
+			}
+			hidden = true
+			hash.Write([]byte(link.ID + ","))
+		}
+		return kept
+	}
+	categories := make([]dashboardCategory, len(d.Categories))
+	for i, c := range d.Categories {
+		c.Links = keep(c.Links)
+		if c.collapseState == collapseAuto && collapseThreshold > 0 {
+			c.Collapsed = len(c.Links) > collapseThreshold
+		}
+		categories[i] = c
+	}
+	d.Categories = categories
+	d.QuickLinks = keep(d.QuickLinks)
+	d.FavoritesBar = keep(d.FavoritesBar)
+	if !hidden {
+		return d, 0
+	}
+	return d, hash.Sum64()
+}
+
+// defaultLinkSort keeps the manual drag-and-drop order.
+const defaultLinkSort = "position"
+
+// defaultDashboardView shows links as cards with their descriptions; the
+// alternative is "compact", a dense list for small screens.
+const defaultDashboardView = "detailed"
+
+// linkSortOrders maps the dashboard's sort parameter to the ORDER BY used
+// for links. Keys are the only accepted values, so the clause is never
+// built from user input.
+var linkSortOrders = map[string]string{
+	"position": "l.position ASC, l.id ASC",
+	"name":     "l.name COLLATE " + nameCollation + " ASC, l.id ASC",
+	"recent":   "l.created_at DESC, l.id DESC",
+	"popular":  "l.click_count DESC, l.position ASC, l.id ASC",
+}
+
+// Orders for the stale and expiring lists, which the dashboard's sort
+// parameter does not offer.
+const (
+	staleLinkOrder    = "l.last_opened_at ASC, l.id ASC"
+	expiringLinkOrder = "l.expires_at ASC, l.id ASC"
+	// flatLinkOrder follows the dashboard layout and ends on the link id,
+	// so pages of /api/links never overlap.
+	flatLinkOrder = "c.panel_id ASC, c.position ASC, c.id ASC, l.position ASC, l.id ASC"
+)
+
+// sqlQuery assembles a SELECT from fixed SQL fragments plus arguments.
+// Fragments given to where and orderBy must be constants; anything from a
+// request travels as an argument, so it is never spliced into the SQL.
+type sqlQuery struct {
+	base   string
+	conds  []string
+	args   []any
+	order  string
+	limit  int
+	offset int
+}
+
+// newSQLQuery starts a query from base, the SELECT and FROM clauses with
+// any arguments they take.
+func newSQLQuery(base string, args ...any) *sqlQuery {
+	return &sqlQuery{base: base, args: args}
+}
+
+// where adds a condition, ANDed with the others.
+func (q *sqlQuery) where(cond string, args ...any) *sqlQuery {
+	q.conds = append(q.conds, "("+cond+")")
+	q.args = append(q.args, args...)
+	return q
+}
+
+func (q *sqlQuery) orderBy(clause string) *sqlQuery {
+	q.order = clause
+	return q
+}
+
+// page keeps limit rows after skipping offset; a limit of 0 keeps every
+// row after offset.
+func (q *sqlQuery) page(limit, offset int) *sqlQuery {
+	q.limit = limit
+	q.offset = offset
+	return q
+}
+
+// build returns the SQL and its arguments in placeholder order.
+func (q *sqlQuery) build() (string, []any) {
+	var b strings.Builder
+	b.WriteString(q.base)
+	args := append([]any(nil), q.args...)
+	if len(q.conds) > 0 {
+		b.WriteString(" WHERE ")
+		b.WriteString(strings.Join(q.conds, " AND "))
+	}
+	if q.order != "" {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(q.order)
+	}
+	// SQLite only takes OFFSET after a LIMIT, and -1 means no limit.
+	if q.limit > 0 || q.offset > 0 {
+		limit := q.limit
+		if limit <= 0 {
+			limit = -1
+		}
+		b.WriteString(" LIMIT ?")
+		args = append(args, limit)
+		if q.offset > 0 {
+			b.WriteString(" OFFSET ?")
+			args = append(args, q.offset)
+		}
+	}
+	return b.String(), args
+}
+
+// linkFilter narrows a query over "links l JOIN categories c". Zero
+// fields do not filter.
+type linkFilter struct {
+	PanelID    int64
+	CategoryID int64
+	// Search keeps links whose name or, unless NameOnly, URL contains
+	// it, ignoring case.
+	Search   string
+	NameOnly bool
+	// OpenedBefore keeps links last opened before this Unix time,
+	// including ones never opened.
+	OpenedBefore int64
+	// ExpiresBy keeps links with an expiry at or before this Unix time.
+	ExpiresBy int64
+	// Order is one of the constant orders above or in linkSortOrders.
+	Order  string
+	Limit  int
+	Offset int
+}
+
+func (f linkFilter) apply(q *sqlQuery) *sqlQuery {
+	if f.PanelID != 0 {
+		q.where(`c.panel_id = ?`, f.PanelID)
+	}
+	if f.CategoryID != 0 {
+		q.where(`l.category_id = ?`, f.CategoryID)
+	}
+	if f.Search != "" {
+		pattern := escapeLike(f.Search)
+		if f.NameOnly {
+			q.where(`l.name LIKE '%' || ? || '%' ESCAPE '\'`, pattern)
+		} else {
+			q.where(`l.name LIKE '%' || ? || '%' ESCAPE '\' OR l.url LIKE '%' || ? || '%' ESCAPE '\'`, pattern, pattern)
+		}
+	}
+	if f.OpenedBefore != 0 {
+		q.where(`l.last_opened_at < ?`, f.OpenedBefore)
+	}
+	if f.ExpiresBy != 0 {
+		q.where(`l.expires_at > 0 AND l.expires_at <= ?`, f.ExpiresBy)
+	}
+	return q.orderBy(f.Order).page(f.Limit, f.Offset)
+}
+
+// parsePage reads the limit and offset query parameters of a list
+// endpoint. A missing limit is 0, meaning no limit; larger ones are
+// capped at maxLimit.
+func parsePage(r *http.Request, maxLimit int) (int, int, error) {
+	limit, offset := 0, 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
 		}
-		catIDs = append(catIDs, id)
+		limit = min(parsed, maxLimit)
 	}
-	catRows.Close()
+	if raw := strings.TrimSpace(r.URL.Query().Get("offset")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
 
-	for _, id := range catIDs {
-		if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE category_id = ?`, id); err != nil {
-			http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-			return
+// defaultCategorySort keeps the manual drag-and-drop order, which is how
+// categories were listed before CATEGORY_SORT existed.
+const defaultCategorySort = "position"
+
+// categorySortOrders maps CATEGORY_SORT values to the ORDER BY used for
+// categories. Like linkSortOrders, only these keys are accepted.
+var categorySortOrders = map[string]string{
+	"position":  "position ASC, id ASC",
+	"name_asc":  "name COLLATE " + nameCollation + " ASC, id ASC",
+	"name_desc": "name COLLATE " + nameCollation + " DESC, id ASC",
+}
+
+// defaultLocale is the LOCALE used when none is set.
+const defaultLocale = "en"
+
+// nameCollation is the SQLite collation the name sorts use. main
+// registers it for LOCALE before opening the database.
+const nameCollation = "locale_name"
+
+// newNameComparer returns a case-insensitive comparison of names for the
+// locale. Without a locale it folds ASCII letters only, like NOCASE.
+func newNameComparer(locale string) func(a, b string) int {
+	if locale == "" {
+		return func(a, b string) int {
+			return strings.Compare(foldASCII(a), foldASCII(b))
 		}
 	}
-	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE panel_id = ?`, panelID); err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-		return
+	// A Collator keeps scratch buffers, so calls must not overlap.
+	var mu sync.Mutex
+	c := collate.New(language.Make(locale), collate.IgnoreCase)
+	return func(a, b string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return c.CompareString(a, b)
 	}
-	if _, err := tx.ExecContext(ctx, `DELETE FROM panels WHERE id = ?`, panelID); err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-		return
+}
+
+// foldASCII lowercases A-Z and leaves every other character alone.
+func foldASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
+
+// ORDER_BY_INSERTION clauses. They stand in for the position orders
+// only; name, recent, and popular sorts are kept as chosen.
+const (
+	insertionLinkOrder     = "l.id ASC"
+	insertionCategoryOrder = "id ASC"
+)
+
+// categoryOrder returns the ORDER BY clause for categories under
+// CATEGORY_SORT and ORDER_BY_INSERTION.
+func (c config) categoryOrder() string {
+	if c.orderByInsertion && c.categorySort == defaultCategorySort {
+		return insertionCategoryOrder
 	}
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "failed to delete panel", http.StatusInternalServerError)
-		return
+	return categorySortOrders[c.categorySort]
+}
+
+func (s *server) categorySortOrder() string {
+	if s.categoryOrder == "" {
+		return categorySortOrders[defaultCategorySort]
 	}
+	return s.categoryOrder
+}
 
-	s.renderDashboard(w, 0)
+// dashboardCache keeps rendered dashboard data per panel and sort order.
+// Entries belong to the version they were built at and are dropped as
+// soon as markChanged moves the version on, so a mutation always forces a
+// rebuild.
+type dashboardCache struct {
+	mu      sync.RWMutex
+	version int64
+	entries map[dashboardCacheKey]dashboardData
 }
 
-func (s *server) handleUpdatePanelNotes(w http.ResponseWriter, r *http.Request, panelID int64) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
+type dashboardCacheKey struct {
+	panelID int64
+	sort    string
+}
+
+func (c *dashboardCache) get(key dashboardCacheKey, version int64) (dashboardData, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.version != version {
+		return dashboardData{}, false
+	}
+	data, ok := c.entries[key]
+	return data, ok
+}
+
+func (c *dashboardCache) put(key dashboardCacheKey, version int64, data dashboardData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if version < c.version {
 		return
 	}
-	notes := strings.TrimSpace(r.FormValue("notes"))
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
-	if _, err := s.db.ExecContext(ctx, `UPDATE panels SET notes = ? WHERE id = ?`, notes, panelID); err != nil {
-		http.Error(w, "failed to save notes", http.StatusInternalServerError)
-		return
+	if version != c.version || c.entries == nil {
+		c.version = version
+		c.entries = map[dashboardCacheKey]dashboardData{}
 	}
-	w.WriteHeader(http.StatusNoContent)
+	c.entries[key] = data
 }
 
-func (s *server) handleClearPanelNotes(w http.ResponseWriter, r *http.Request, panelID int64) {
+// getDashboardData serves from the cache while nothing has changed. The
+// version is read before loading, so data built across a concurrent
+// mutation is filed under the old version and never served.
+func (s *server) getDashboardData(parent context.Context, requestedPanelID int64, sortKey string) (dashboardData, error) {
+	version := s.version.Load()
+	key := dashboardCacheKey{panelID: requestedPanelID, sort: sortKey}
+	if data, ok := s.cache.get(key, version); ok {
+		return data, nil
+	}
+	var data dashboardData
+	err := retryBusy(parent, func() error {
+		var err error
+		data, err = s.loadDashboardData(parent, requestedPanelID, sortKey)
+		return err
+	})
+	if err != nil {
+		return dashboardData{}, err
+	}
+	s.cache.put(key, version, data)
+	return data, nil
+}
+
+// stateHashCache keeps the /api/state hash for the version it was
+// computed at, so polling clients cost nothing between mutations.
+type stateHashCache struct {
+	mu      sync.Mutex
+	version int64
+	hash    string
+}
+
+// handleState answers {"version": N, "hash": "..."} for sync clients.
+// version moves on every mutation; hash is a SHA-256 of every panel's
+// dashboard data, so it only changes when the content does.
+func (s *server) handleState(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
 	defer cancel()
-	if _, err := s.db.ExecContext(ctx, `UPDATE panels SET notes = '' WHERE id = ?`, panelID); err != nil {
-		http.Error(w, "failed to clear notes", http.StatusInternalServerError)
+
+	version := s.version.Load()
+	hash, err := s.stateHash(ctx, version)
+	if err != nil {
+		http.Error(w, "failed to load state", http.StatusInternalServerError)
 		return
 	}
-	s.renderDashboard(w, panelID)
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, map[string]any{"version": version, "hash": hash})
 }
 
-func (s *server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+// stateHash digests the JSON of each panel's dashboard in panel order.
+// Stats are left out because "recently added" depends on the clock.
+func (s *server) stateHash(ctx context.Context, version int64) (string, error) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	if s.state.hash != "" && s.state.version == version {
+		return s.state.hash, nil
 	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+	panels, err := s.loadPanels(ctx)
+	if err != nil {
+		return "", err
 	}
-	name := strings.TrimSpace(r.FormValue("name"))
-	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
-	if name == "" {
-		http.Error(w, "category name is required", http.StatusBadRequest)
-		return
+	digest := sha256.New()
+	encoder := json.NewEncoder(digest)
+	for _, p := range panels {
+		data, err := s.getDashboardData(ctx, p.ID, defaultLinkSort)
+		if err != nil {
+			return "", err
+		}
+		data.Stats = dashboardStats{}
+		if err := encoder.Encode(data); err != nil {
+			return "", err
+		}
 	}
+	s.state.version = version
+	s.state.hash = hex.EncodeToString(digest.Sum(nil))
+	return s.state.hash, nil
+}
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+func (s *server) loadDashboardData(parent context.Context, requestedPanelID int64, sortKey string) (dashboardData, error) {
+	ctx, cancel := context.WithTimeout(parent, requestTimeout)
 	defer cancel()
-	activePanelID, err := s.resolvePanelID(ctx, activePanelID)
+
+	panels, err := s.loadPanels(ctx)
 	if err != nil {
-		http.Error(w, "panel not found", http.StatusBadRequest)
-		return
+		return dashboardData{}, err
+	}
+	if len(panels) == 0 {
+		return dashboardData{}, errors.New("no panels available")
 	}
 
-	var nextPos int
-	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM categories WHERE panel_id = ?`, activePanelID).Scan(&nextPos); err != nil {
-		http.Error(w, "failed to create category", http.StatusInternalServerError)
-		return
+	activePanelID := requestedPanelID
+	if activePanelID == 0 {
+		activePanelID = panels[0].ID
+	}
+	if !panelExists(panels, activePanelID) {
+		activePanelID = panels[0].ID
+	}
+	panelNotes := ""
+	if err := s.db.QueryRowContext(ctx, `SELECT notes FROM panels WHERE id = ?`, activePanelID).Scan(&panelNotes); err != nil {
+		return dashboardData{}, err
+	}
+	siteTitle, siteSubtitle, err := s.siteTitle(ctx)
+	if err != nil {
+		return dashboardData{}, err
+	}
+	accentColor, backgroundColor, err := s.themeColors(ctx)
+	if err != nil {
+		return dashboardData{}, err
 	}
 
-	_, err = s.db.ExecContext(ctx, `INSERT INTO categories(panel_id, name, position) VALUES(?, ?, ?)`, activePanelID, name, nextPos)
+	presets, err := s.loadPresets(ctx)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "unique") {
-			http.Error(w, "category already exists in this panel", http.StatusConflict)
-			return
+		return dashboardData{}, err
+	}
+
+	categories, categoryMap, err := s.loadCategoriesForPanel(ctx, activePanelID)
+	if err != nil {
+		return dashboardData{}, err
+	}
+
+	orderBy, ok := linkSortOrders[sortKey]
+	if !ok {
+		return dashboardData{}, fmt.Errorf("unknown sort %q", sortKey)
+	}
+	if sortKey == defaultLinkSort && s.insertionOrder {
+		orderBy = insertionLinkOrder
+	}
+
+	allLinks := make([]dashboardLink, 0, 64)
+	favoritesCount := 0
+
+	query, args := linkFilter{PanelID: activePanelID, Order: orderBy}.apply(newSQLQuery(
+		`SELECT l.id, l.name, l.url, l.description, l.logo_url, l.category_id, l.click_count, l.last_opened_at,
+		        l.og_title, l.og_description, l.og_image, l.target_blank, l.last_status, l.last_checked,
+		        COALESCE(i.updated_at, 0), l.visible_from, l.visible_to, l.confirm, f.link_id IS NOT NULL, l.hotkey,
+		        l.expires_at, l.enabled = 0
+		 FROM links l
+		 JOIN categories c ON c.id = l.category_id
+		 LEFT JOIN link_icons i ON i.link_id = l.id
+		 LEFT JOIN favorites f ON f.link_id = l.id`,
+	)).build()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return dashboardData{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name, url, description, logo string
+		var categoryID int64
+		var clickCount int
+		var lastOpened int64
+		var og openGraph
+		var targetBlank, confirm, favorite, disabled bool
+		var lastStatus int
+		var lastChecked, iconVersion, expiresAt int64
+		var visibleFrom, visibleTo, hotkey string
+		if err := rows.Scan(&id, &name, &url, &description, &logo, &categoryID, &clickCount, &lastOpened, &og.Title, &og.Description, &og.Image, &targetBlank, &lastStatus, &lastChecked, &iconVersion, &visibleFrom, &visibleTo, &confirm, &favorite, &hotkey, &expiresAt, &disabled); err != nil {
+			return dashboardData{}, err
+		}
+		if err := s.cipher.openAll(&url, &description); err != nil {
+			return dashboardData{}, err
+		}
+		cat, ok := categoryMap[categoryID]
+		if !ok {
+			continue
+		}
+		item := dashboardLink{
+			ID:              strconv.FormatInt(id, 10),
+			CategoryID:      strconv.FormatInt(categoryID, 10),
+			CategoryName:    cat.Name,
+			Name:            name,
+			URL:             url,
+			Description:     description,
+			DescriptionHTML: renderMarkdown(description),
+			LogoURL:         logo,
+			IconDataURI:     monogramDataURI(name, url),
+			IconVersion:     iconVersion,
+			ClickCount:      clickCount,
+			LastOpenedAt:    unixOrZero(lastOpened),
+			OGTitle:         og.Title,
+			OGDescription:   og.Description,
+			OGImage:         og.Image,
+			TargetBlank:     targetBlank,
+			Confirm:         confirm,
+			Favorite:        favorite,
+			LastStatus:      lastStatus,
+			LastCheckedAt:   unixOrZero(lastChecked),
+			VisibleFrom:     visibleFrom,
+			VisibleTo:       visibleTo,
+			Hotkey:          hotkey,
+			ExpiresAt:       s.localTime(expiresAt),
+			Disabled:        disabled,
+		}
+		cat.Links = append(cat.Links, item)
+		if cat.Archived {
+			continue
+		}
+		allLinks = append(allLinks, item)
+		if strings.EqualFold(cat.Name, "Favorites") {
+			favoritesCount++
 		}
-		http.Error(w, "failed to create category", http.StatusInternalServerError)
-		return
 	}
-	s.renderDashboard(w, activePanelID)
-}
+	if err := rows.Err(); err != nil {
+		return dashboardData{}, err
+	}
 
-func (s *server) handleCategoryActions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+	favoritesBar, err := s.loadFavoritesBar(ctx)
+	if err != nil {
+		return dashboardData{}, err
 	}
-	path := strings.TrimPrefix(r.URL.Path, "/actions/categories/")
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) != 2 || parts[1] != "delete" {
-		http.NotFound(w, r)
-		return
+
+	recentAdded := len(allLinks)
+	if recentAdded > 3 {
+		recentAdded = 3
 	}
-	categoryID := parseInt64OrZero(parts[0])
-	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
-	if categoryID == 0 {
-		http.Error(w, "invalid category id", http.StatusBadRequest)
-		return
+
+	quickLinks := allLinks
+	if len(quickLinks) > 5 {
+		quickLinks = quickLinks[:5]
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
+	active := make([]dashboardCategory, 0, len(categories))
+	archived := make([]dashboardCategory, 0)
+	for _, c := range categories {
+		if c.Archived {
+			archived = append(archived, c)
+		} else {
+			active = append(active, c)
+		}
+	}
+	categories = active
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	panelView := make([]dashboardPanel, 0, len(panels))
+	for _, p := range panels {
+		panelView = append(panelView, dashboardPanel{ID: strconv.FormatInt(p.ID, 10), Name: p.Name})
+	}
+
+	return dashboardData{
+		Panels:       panelView,
+		ActivePanel:  strconv.FormatInt(activePanelID, 10),
+		Categories:   categories,
+		QuickLinks:   quickLinks,
+		FavoritesBar: favoritesBar,
+		Presets:      presets,
+		// Links of archived categories are loaded too so exports keep them.
+		ArchivedCategories: archived,
+		Stats: dashboardStats{
+			TotalLinks:      len(allLinks),
+			Favorites:       favoritesCount,
+			RecentAdded:     recentAdded,
+			TotalCategories: len(categories),
+		},
+		SearchHint:      fmt.Sprintf("Search links in %s...", findPanelName(panels, activePanelID)),
+		FormPanelID:     strconv.FormatInt(activePanelID, 10),
+		PanelNotes:      panelNotes,
+		Sort:            sortKey,
+		SiteTitle:       siteTitle,
+		SiteSubtitle:    siteSubtitle,
+		AccentColor:     accentColor,
+		BackgroundColor: backgroundColor,
+		PinRequired:     s.pin != nil,
+	}, nil
+}
+
+type panelRow struct {
+	ID       int64
+	Name     string
+	Position int
+}
+
+func (s *server) loadPanels(ctx context.Context) ([]panelRow, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, position FROM panels ORDER BY position ASC, id ASC`)
 	if err != nil {
-		http.Error(w, "failed to delete category", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	defer tx.Rollback()
-	if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE category_id = ?`, categoryID); err != nil {
-		http.Error(w, "failed to delete category", http.StatusInternalServerError)
-		return
+	defer rows.Close()
+
+	items := make([]panelRow, 0, 8)
+	for rows.Next() {
+		var row panelRow
+		if err := rows.Scan(&row.ID, &row.Name, &row.Position); err != nil {
+			return nil, err
+		}
+		items = append(items, row)
+	}
+	return items, rows.Err()
+}
+
+// loadCategoriesForPanel returns the panel's categories in CATEGORY_SORT
+// order, except that the home category, if it is on this panel, comes
+// first. A home setting naming a deleted category is ignored.
+func (s *server) loadCategoriesForPanel(ctx context.Context, panelID int64) ([]dashboardCategory, map[int64]*dashboardCategory, error) {
+	homeID, err := s.homeCategoryID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaultSetting, err := s.getSetting(ctx, settingDefaultCategory)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaultID := parseInt64OrZero(defaultSetting)
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, description, collapsed, archived,
+		        EXISTS(SELECT 1 FROM category_shares s WHERE s.category_id = categories.id)
+		 FROM categories WHERE panel_id = ? ORDER BY `+s.categorySortOrder(),
+		panelID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]dashboardCategory, 0, 16)
+	ids := make([]int64, 0, 16)
+	for rows.Next() {
+		var id int64
+		var name, description string
+		var collapseState int
+		var archived, shared bool
+		if err := rows.Scan(&id, &name, &description, &collapseState, &archived, &shared); err != nil {
+			return nil, nil, err
+		}
+		item := dashboardCategory{
+			ID:            strconv.FormatInt(id, 10),
+			Name:          name,
+			Description:   description,
+			Collapsed:     collapseState == collapseClosed,
+			collapseState: collapseState,
+			Home:          id == homeID,
+			Default:       id == defaultID,
+			Archived:      archived,
+			Shared:        shared,
+			Links:         []dashboardLink{},
+		}
+		if item.Home {
+			categories = append([]dashboardCategory{item}, categories...)
+			ids = append([]int64{id}, ids...)
+			continue
+		}
+		categories = append(categories, item)
+		ids = append(ids, id)
 	}
-	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = ?`, categoryID); err != nil {
-		http.Error(w, "failed to delete category", http.StatusInternalServerError)
-		return
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
 	}
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "failed to delete category", http.StatusInternalServerError)
-		return
+	catMap := make(map[int64]*dashboardCategory, len(categories))
+	for i, id := range ids {
+		catMap[id] = &categories[i]
 	}
-	s.renderDashboard(w, activePanelID)
+	return categories, catMap, nil
 }
 
-func (s *server) handleCreateLink(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
-	}
+// settingHomeCategory is the settings key for HOME_CATEGORY, the id of
+// the category pinned to the top of its panel.
+const settingHomeCategory = "home_category"
 
-	name := strings.TrimSpace(r.FormValue("name"))
-	url := strings.TrimSpace(r.FormValue("url"))
-	description := strings.TrimSpace(r.FormValue("description"))
-	categoryID := parseInt64OrZero(r.FormValue("category_id"))
-	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+// settingDefaultCategory is the settings key for DEFAULT_CATEGORY, the id
+// of the category new links go to when they name none.
+const settingDefaultCategory = "default_category"
 
-	if name == "" || url == "" || categoryID == 0 {
-		http.Error(w, "name, url, and category are required", http.StatusBadRequest)
-		return
-	}
-	if !isLikelyURL(url) {
-		http.Error(w, "invalid url", http.StatusBadRequest)
-		return
-	}
+// Settings keys for the dashboard's name in the page title and header,
+// and what is shown while they are unset.
+const (
+	settingSiteTitle    = "site_title"
+	settingSiteSubtitle = "site_subtitle"
+	defaultSiteTitle    = "Personal Dashboard"
+	defaultSiteSubtitle = "Your Bookmark Hub"
+)
 
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
+// Settings keys for the theme colors the dashboard overrides its CSS
+// custom properties with.
+const (
+	settingAccentColor     = "accent_color"
+	settingBackgroundColor = "background_color"
+)
 
-	var nextPos int
-	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(position), -1) + 1 FROM links WHERE category_id = ?`, categoryID).Scan(&nextPos); err != nil {
-		http.Error(w, "failed to create link", http.StatusInternalServerError)
-		return
-	}
-	now := time.Now().Unix()
-	logo := derivedLogoURL(url)
-	_, err := s.db.ExecContext(ctx,
-		`INSERT INTO links(name, url, description, logo_url, category_id, position, created_at, updated_at)
-		 VALUES(?, ?, ?, ?, ?, ?, ?, ?)`,
-		name, url, description, logo, categoryID, nextPos, now, now,
-	)
-	if err != nil {
-		http.Error(w, "failed to create link", http.StatusInternalServerError)
-		return
+// uncategorizedName is the category created on the active panel for
+// links without a category while DEFAULT_CATEGORY is unset or deleted.
+const uncategorizedName = "Uncategorized"
+
+func (s *server) getSetting(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
 	}
-	s.renderDashboard(w, activePanelID)
+	return value, err
 }
 
-func (s *server) handleLinkActions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	path := strings.TrimPrefix(r.URL.Path, "/actions/links/")
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) != 2 {
-		http.NotFound(w, r)
-		return
+// Settings keys for DB_PASSPHRASE: the random scrypt salt, and a value
+// sealed with the derived key so a wrong passphrase is caught at startup
+// instead of on the first link read.
+const (
+	settingEncryptionSalt  = "encryption_salt"
+	settingEncryptionCheck = "encryption_check"
+	encryptionCheckText    = "personal_dash"
+)
+
+// sealedPrefix marks a column value sealed by fieldCipher. Values without
+// it are plain text written before DB_PASSPHRASE was set.
+const sealedPrefix = "enc:v1:"
+
+// fieldCipher encrypts individual column values with AES-256-GCM under a
+// key derived from DB_PASSPHRASE. A nil *fieldCipher passes values
+// through, so callers need not check whether encryption is on.
+type fieldCipher struct {
+	aead cipher.AEAD
+}
+
+func newFieldCipher(passphrase string, salt []byte) (*fieldCipher, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
 	}
-	id := parseInt64OrZero(parts[0])
-	if id == 0 {
-		http.Error(w, "invalid link id", http.StatusBadRequest)
-		return
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
-	switch parts[1] {
-	case "delete":
-		s.handleDeleteLink(w, r, id)
-	case "update":
-		s.handleUpdateLink(w, r, id)
-	default:
-		http.NotFound(w, r)
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
 	}
+	return &fieldCipher{aead: aead}, nil
 }
 
-func (s *server) handleDeleteLink(w http.ResponseWriter, r *http.Request, id int64) {
-	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
-	if _, err := s.db.ExecContext(ctx, `DELETE FROM links WHERE id = ?`, id); err != nil {
-		http.Error(w, "failed to delete link", http.StatusInternalServerError)
-		return
+// seal encrypts value with a fresh random nonce. Empty values stay empty.
+func (c *fieldCipher) seal(value string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
 	}
-	s.renderDashboard(w, activePanelID)
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
 }
 
-func (s *server) handleUpdateLink(w http.ResponseWriter, r *http.Request, id int64) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+// open decrypts a value written by seal and returns plain text unchanged.
+func (c *fieldCipher) open(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
 	}
-	name := strings.TrimSpace(r.FormValue("name"))
-	url := strings.TrimSpace(r.FormValue("url"))
-	description := strings.TrimSpace(r.FormValue("description"))
-	logoOverride := strings.TrimSpace(r.FormValue("custom_logo_url"))
-	categoryID := parseInt64OrZero(r.FormValue("category_id"))
-	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
-
-	if name == "" || url == "" || categoryID == 0 {
-		http.Error(w, "name, url, and category are required", http.StatusBadRequest)
-		return
+	if c == nil {
+		return "", errors.New("value is encrypted but DB_PASSPHRASE is not set")
 	}
-	if !isLikelyURL(url) {
-		http.Error(w, "invalid url", http.StatusBadRequest)
-		return
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
 	}
-	logo := derivedLogoURL(url)
-	if logoOverride != "" {
-		logo = logoOverride
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("encrypted value is truncated")
 	}
-
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
-	now := time.Now().Unix()
-	_, err := s.db.ExecContext(ctx,
-		`UPDATE links
-		 SET name = ?, url = ?, description = ?, logo_url = ?, custom_logo_url = ?, category_id = ?, updated_at = ?
-		 WHERE id = ?`,
-		name, url, description, logo, logoOverride, categoryID, now, id,
-	)
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
 	if err != nil {
-		http.Error(w, "failed to update link", http.StatusInternalServerError)
-		return
+		return "", err
 	}
-	s.renderDashboard(w, activePanelID)
+	return string(plain), nil
 }
 
-func (s *server) handleReorderCategories(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+// openAll decrypts each value in place.
+func (c *fieldCipher) openAll(values ...*string) error {
+	for _, value := range values {
+		plain, err := c.open(*value)
+		if err != nil {
+			return err
+		}
+		*value = plain
 	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+	return nil
+}
+
+// sealLink encrypts the link columns kept secret at rest.
+func (c *fieldCipher) sealLink(url string, description string) (string, string, error) {
+	sealedURL, err := c.seal(url)
+	if err != nil {
+		return "", "", err
 	}
-	panelID := parseInt64OrZero(r.FormValue("panel_id"))
-	ordered := parseIDList(r.FormValue("ordered_ids"))
-	if panelID == 0 {
-		http.Error(w, "invalid panel id", http.StatusBadRequest)
-		return
+	sealedDescription, err := c.seal(description)
+	if err != nil {
+		return "", "", err
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	return sealedURL, sealedDescription, nil
+}
+
+// setupFieldCipher derives the link encryption key from passphrase,
+// creating the salt on first use, and refuses to start when the
+// passphrase does not match the one the database was encrypted with or
+// is missing for an encrypted database. Links stored before encryption
+// was turned on are encrypted in the same transaction. Turning encryption
+// off again is not supported.
+func setupFieldCipher(db *sql.DB, passphrase string) (*fieldCipher, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
 	defer cancel()
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer tx.Rollback()
-	for idx, id := range ordered {
-		if _, err := tx.ExecContext(ctx, `UPDATE categories SET position = ? WHERE id = ? AND panel_id = ?`, idx, id, panelID); err != nil {
-			http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
-			return
+	setting := func(key string) (string, error) {
+		var value string
+		err := tx.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
 		}
+		return value, err
 	}
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "failed to reorder categories", http.StatusInternalServerError)
-		return
+	check, err := setting(settingEncryptionCheck)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		if check != "" {
+			return nil, errors.New("database is encrypted; set DB_PASSPHRASE")
+		}
+		return nil, nil
 	}
-	w.WriteHeader(http.StatusNoContent)
-}
 
-func (s *server) handleReorderLinks(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+	encodedSalt, err := setting(settingEncryptionSalt)
+	if err != nil {
+		return nil, err
 	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "invalid form", http.StatusBadRequest)
-		return
+	var salt []byte
+	if encodedSalt == "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	} else if salt, err = base64.RawStdEncoding.DecodeString(encodedSalt); err != nil {
+		return nil, fmt.Errorf("read encryption salt: %w", err)
 	}
-	categoryID := parseInt64OrZero(r.FormValue("category_id"))
-	ordered := parseIDList(r.FormValue("ordered_ids"))
-	if categoryID == 0 {
-		http.Error(w, "invalid category id", http.StatusBadRequest)
-		return
+	c, err := newFieldCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if check != "" {
+		if text, err := c.open(check); err != nil || text != encryptionCheckText {
+			return nil, errors.New("DB_PASSPHRASE does not match the passphrase this database was encrypted with")
+		}
+		return c, nil
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
-	defer cancel()
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	sealedCheck, err := c.seal(encryptionCheckText)
 	if err != nil {
-		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
-	defer tx.Rollback()
-	now := time.Now().Unix()
-	for idx, id := range ordered {
-		if _, err := tx.ExecContext(ctx, `UPDATE links SET category_id = ?, position = ?, updated_at = ? WHERE id = ?`, categoryID, idx, now, id); err != nil {
-			http.Error(w, "failed to reorder links", http.StatusInternalServerError)
-			return
+	for key, value := range map[string]string{
+		settingEncryptionSalt:  base64.RawStdEncoding.EncodeToString(salt),
+		settingEncryptionCheck: sealedCheck,
+	} {
+		if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO settings(key, value) VALUES(?, ?)`, key, value); err != nil {
+			return nil, err
 		}
 	}
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "failed to reorder links", http.StatusInternalServerError)
-		return
+	if err := sealExistingLinksTx(ctx, tx, c); err != nil {
+		return nil, fmt.Errorf("encrypt existing links: %w", err)
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return c, tx.Commit()
 }
 
-func (s *server) renderDashboard(w http.ResponseWriter, requestedPanelID int64) {
-	data, err := s.getDashboardData(context.Background(), requestedPanelID)
+func sealExistingLinksTx(ctx context.Context, tx *sql.Tx, c *fieldCipher) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, url, description FROM links`)
 	if err != nil {
-		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
-		return
+		return err
 	}
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.templates.ExecuteTemplate(w, "dashboard.html", data); err != nil {
-		http.Error(w, "failed to render template", http.StatusInternalServerError)
+	type plainLink struct {
+		id               int64
+		url, description string
 	}
+	var links []plainLink
+	for rows.Next() {
+		var link plainLink
+		if err := rows.Scan(&link.id, &link.url, &link.description); err != nil {
+			rows.Close()
+			return err
+		}
+		links = append(links, link)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, link := range links {
+		sealedURL, sealedDescription, err := c.sealLink(link.url, link.description)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE links SET url = ?, description = ? WHERE id = ?`, sealedURL, sealedDescription, link.id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *server) getDashboardData(parent context.Context, requestedPanelID int64) (dashboardData, error) {
-	ctx, cancel := context.WithTimeout(parent, requestTimeout)
-	defer cancel()
+func (s *server) homeCategoryID(ctx context.Context) (int64, error) {
+	value, err := s.getSetting(ctx, settingHomeCategory)
+	if err != nil {
+		return 0, err
+	}
+	return parseInt64OrZero(value), nil
+}
 
-	panels, err := s.loadPanels(ctx)
+// defaultCategoryID picks the category for a new link that names none:
+// DEFAULT_CATEGORY while it still exists, otherwise an "Uncategorized"
+// category on the panel, or on the first panel with AUTO_UNCATEGORIZED.
+// It writes nothing: when Uncategorized does not exist yet, id is 0 and
+// panelID is where the caller should create it.
+func (s *server) defaultCategoryID(ctx context.Context, activePanelID int64) (id int64, panelID int64, err error) {
+	value, err := s.getSetting(ctx, settingDefaultCategory)
 	if err != nil {
-		return dashboardData{}, err
+		return 0, 0, err
 	}
-	if len(panels) == 0 {
-		return dashboardData{}, errors.New("no panels available")
+	if id := parseInt64OrZero(value); id > 0 {
+		err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, id).Scan(&id)
+		if err == nil {
+			return id, 0, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, err
+		}
 	}
-
-	activePanelID := requestedPanelID
-	if activePanelID == 0 {
-		activePanelID = panels[0].ID
+	if s.autoUncategorized {
+		activePanelID = 0
 	}
-	if !panelExists(panels, activePanelID) {
-		activePanelID = panels[0].ID
+	panelID, err = s.resolvePanelID(ctx, activePanelID)
+	if err != nil {
+		return 0, 0, err
 	}
-	panelNotes := ""
-	if err := s.db.QueryRowContext(ctx, `SELECT notes FROM panels WHERE id = ?`, activePanelID).Scan(&panelNotes); err != nil {
-		return dashboardData{}, err
+	err = s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE panel_id = ? AND name = ?`, panelID, uncategorizedName).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, panelID, nil
 	}
+	return id, panelID, err
+}
 
-	categories, categoryMap, err := s.loadCategoriesForPanel(ctx, activePanelID)
+// ensureUncategorized returns the AUTO_UNCATEGORIZED fallback category,
+// the Uncategorized category on the first panel, creating it when
+// missing.
+func (s *server) ensureUncategorized(ctx context.Context) (int64, error) {
+	panelID, err := s.resolvePanelID(ctx, 0)
 	if err != nil {
-		return dashboardData{}, err
+		return 0, err
 	}
-
-	allLinks := make([]dashboardLink, 0, 64)
-	favoritesCount := 0
-
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT l.id, l.name, l.url, l.description, l.logo_url, l.category_id
-		 FROM links l
-		 JOIN categories c ON c.id = l.category_id
-		 WHERE c.panel_id = ?
-		 ORDER BY l.position ASC, l.id ASC`,
-		activePanelID,
-	)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return dashboardData{}, err
+		return 0, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
+	id, err := s.ensureCategoryTx(ctx, tx, panelID, uncategorizedName)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
 
-	for rows.Next() {
-		var id int64
-		var name, url, description, logo string
-		var categoryID int64
-		if err := rows.Scan(&id, &name, &url, &description, &logo, &categoryID); err != nil {
-			return dashboardData{}, err
-		}
-		cat, ok := categoryMap[categoryID]
-		if !ok {
-			continue
+// protectedCategory reports whether categoryID is the AUTO_UNCATEGORIZED
+// fallback, which may not be deleted or merged away while the option is
+// on.
+func (s *server) protectedCategory(ctx context.Context, categoryID int64) (bool, error) {
+	if !s.autoUncategorized {
+		return false, nil
+	}
+	id, err := s.ensureUncategorized(ctx)
+	return id == categoryID, err
+}
+
+// handleSetHomeCategory pins category_id as the home category, or clears
+// the setting when category_id is empty.
+func (s *server) handleSetHomeCategory(w http.ResponseWriter, r *http.Request) {
+	s.setCategorySetting(w, r, settingHomeCategory, "home category")
+}
+
+// handleSetDefaultCategory makes category_id where links without a
+// category go, or clears the setting when category_id is empty.
+func (s *server) handleSetDefaultCategory(w http.ResponseWriter, r *http.Request) {
+	s.setCategorySetting(w, r, settingDefaultCategory, "default category")
+}
+
+// setCategorySetting stores category_id under key after checking the
+// category exists; label names the setting in error messages.
+func (s *server) setCategorySetting(w http.ResponseWriter, r *http.Request, key string, label string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	rawID := strings.TrimSpace(r.FormValue("category_id"))
+	categoryID := parseInt64OrZero(rawID)
+	if rawID != "" && categoryID <= 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, fieldErrors{"category_id": "must be a category id"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	if categoryID == 0 {
+		if _, err := s.execRetry(ctx, `DELETE FROM settings WHERE key = ?`, key); err != nil {
+			http.Error(w, "failed to clear "+label, http.StatusInternalServerError)
+			return
 		}
-		item := dashboardLink{
-			ID:           strconv.FormatInt(id, 10),
-			CategoryID:   strconv.FormatInt(categoryID, 10),
-			CategoryName: cat.Name,
-			Name:         name,
-			URL:          url,
-			Description:  description,
-			LogoURL:      logo,
+	} else {
+		var exists int64
+		if err := s.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE id = ?`, categoryID).Scan(&exists); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "category not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "failed to set "+label, http.StatusInternalServerError)
+			return
 		}
-		cat.Links = append(cat.Links, item)
-		allLinks = append(allLinks, item)
-		if strings.EqualFold(cat.Name, "Favorites") {
-			favoritesCount++
+		if _, err := s.execRetry(ctx,
+			`INSERT INTO settings(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+			key, strconv.FormatInt(categoryID, 10),
+		); err != nil {
+			http.Error(w, "failed to set "+label, http.StatusInternalServerError)
+			return
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return dashboardData{}, err
+	s.markChanged(changeEvent{Type: "settings.updated"})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, map[string]string{"category_id": rawID})
+		return
 	}
+	s.renderDashboard(w, activePanelID)
+}
 
-	recentAdded := len(allLinks)
-	if recentAdded > 3 {
-		recentAdded = 3
+// siteTitle returns the configured title and subtitle, falling back to
+// the defaults for unset ones.
+func (s *server) siteTitle(ctx context.Context) (string, string, error) {
+	title, err := s.getSetting(ctx, settingSiteTitle)
+	if err != nil {
+		return "", "", err
+	}
+	subtitle, err := s.getSetting(ctx, settingSiteSubtitle)
+	if err != nil {
+		return "", "", err
 	}
+	if title == "" {
+		title = defaultSiteTitle
+	}
+	if subtitle == "" {
+		subtitle = defaultSiteSubtitle
+	}
+	return title, subtitle, nil
+}
 
-	quickLinks := allLinks
-	if len(quickLinks) > 5 {
-		quickLinks = quickLinks[:5]
+// handleSetSiteTitle stores site_title and site_subtitle. An empty value
+// clears that setting so the default shows again.
+func (s *server) handleSetSiteTitle(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	title := strings.TrimSpace(r.FormValue("site_title"))
+	subtitle := strings.TrimSpace(r.FormValue("site_subtitle"))
+	errs := fieldErrors{}
+	if utf8.RuneCountInString(title) > maxNameLength {
+		errs["site_title"] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+	}
+	if utf8.RuneCountInString(subtitle) > maxCategoryDescriptionLength {
+		errs["site_subtitle"] = fmt.Sprintf("must be at most %d characters", maxCategoryDescriptionLength)
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
 	}
 
-	panelView := make([]dashboardPanel, 0, len(panels))
-	for _, p := range panels {
-		panelView = append(panelView, dashboardPanel{ID: strconv.FormatInt(p.ID, 10), Name: p.Name})
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	for _, setting := range []struct{ key, value string }{
+		{settingSiteTitle, title},
+		{settingSiteSubtitle, subtitle},
+	} {
+		var err error
+		if setting.value == "" {
+			_, err = s.execRetry(ctx, `DELETE FROM settings WHERE key = ?`, setting.key)
+		} else {
+			_, err = s.execRetry(ctx,
+				`INSERT INTO settings(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+				setting.key, setting.value,
+			)
+		}
+		if err != nil {
+			http.Error(w, "failed to set site title", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.markChanged(changeEvent{Type: "settings.updated"})
+	if isJSONRequest(r) {
+		title, subtitle, err := s.siteTitle(ctx)
+		if err != nil {
+			http.Error(w, "failed to set site title", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"site_title": title, "site_subtitle": subtitle})
+		return
 	}
+	s.renderDashboard(w, activePanelID)
+}
 
-	return dashboardData{
-		Panels:      panelView,
-		ActivePanel: strconv.FormatInt(activePanelID, 10),
-		Categories:  categories,
-		QuickLinks:  quickLinks,
-		Stats: dashboardStats{
-			TotalLinks:      len(allLinks),
-			Favorites:       favoritesCount,
-			RecentAdded:     recentAdded,
-			TotalCategories: len(categories),
-		},
-		SearchHint:  fmt.Sprintf("Search links in %s...", findPanelName(panels, activePanelID)),
-		FormPanelID: strconv.FormatInt(activePanelID, 10),
-		PanelNotes:  panelNotes,
-	}, nil
+// themeColors returns the accent_color and background_color settings.
+func (s *server) themeColors(ctx context.Context) (string, string, error) {
+	accent, err := s.getSetting(ctx, settingAccentColor)
+	if err != nil {
+		return "", "", err
+	}
+	background, err := s.getSetting(ctx, settingBackgroundColor)
+	if err != nil {
+		return "", "", err
+	}
+	return accent, background, nil
 }
 
-type panelRow struct {
-	ID       int64
-	Name     string
-	Position int
+// validateHexColor accepts a CSS hex color, #rgb or #rrggbb.
+func validateHexColor(color string) string {
+	if color == "" {
+		return ""
+	}
+	if len(color) != 4 && len(color) != 7 || color[0] != '#' {
+		return "must be a hex color like #3d6aff"
+	}
+	for _, c := range color[1:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return "must be a hex color like #3d6aff"
+		}
+	}
+	return ""
 }
 
-func (s *server) loadPanels(ctx context.Context) ([]panelRow, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, name, position FROM panels ORDER BY position ASC, id ASC`)
-	if err != nil {
-		return nil, err
+// handleSetTheme stores accent_color and background_color. An empty
+// value clears that setting so the stylesheet's color shows again.
+func (s *server) handleSetTheme(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	activePanelID := parseInt64OrZero(r.FormValue("active_panel_id"))
+	accent := strings.ToLower(strings.TrimSpace(r.FormValue("accent_color")))
+	background := strings.ToLower(strings.TrimSpace(r.FormValue("background_color")))
+	errs := fieldErrors{}
+	if msg := validateHexColor(accent); msg != "" {
+		errs["accent_color"] = msg
+	}
+	if msg := validateHexColor(background); msg != "" {
+		errs["background_color"] = msg
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, r, http.StatusBadRequest, errs)
+		return
 	}
-	defer rows.Close()
 
-	items := make([]panelRow, 0, 8)
-	for rows.Next() {
-		var row panelRow
-		if err := rows.Scan(&row.ID, &row.Name, &row.Position); err != nil {
-			return nil, err
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	for _, setting := range []struct{ key, value string }{
+		{settingAccentColor, accent},
+		{settingBackgroundColor, background},
+	} {
+		var err error
+		if setting.value == "" {
+			_, err = s.execRetry(ctx, `DELETE FROM settings WHERE key = ?`, setting.key)
+		} else {
+			_, err = s.execRetry(ctx,
+				`INSERT INTO settings(key, value) VALUES(?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
+				setting.key, setting.value,
+			)
+		}
+		if err != nil {
+			http.Error(w, "failed to set theme", http.StatusInternalServerError)
+			return
 		}
-		items = append(items, row)
 	}
-	return items, rows.Err()
+	s.markChanged(changeEvent{Type: "settings.updated"})
+	if isJSONRequest(r) {
+		writeJSON(w, http.StatusOK, map[string]string{"accent_color": accent, "background_color": background})
+		return
+	}
+	s.renderDashboard(w, activePanelID)
 }
 
-func (s *server) loadCategoriesForPanel(ctx context.Context, panelID int64) ([]dashboardCategory, map[int64]*dashboardCategory, error) {
-	rows, err := s.db.QueryContext(ctx,
-		`SELECT id, name FROM categories WHERE panel_id = ? ORDER BY position ASC, id ASC`,
-		panelID,
-	)
+func (s *server) loadPresets(ctx context.Context) ([]dashboardPreset, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name FROM presets ORDER BY name COLLATE NOCASE ASC`)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	categories := make([]dashboardCategory, 0, 16)
-	catMap := make(map[int64]*dashboardCategory)
+	items := make([]dashboardPreset, 0, 8)
 	for rows.Next() {
 		var id int64
 		var name string
 		if err := rows.Scan(&id, &name); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		item := dashboardCategory{ID: strconv.FormatInt(id, 10), Name: name, Links: []dashboardLink{}}
-		categories = append(categories, item)
-		catMap[id] = &categories[len(categories)-1]
+		items = append(items, dashboardPreset{ID: strconv.FormatInt(id, 10), Name: name})
 	}
-	return categories, catMap, rows.Err()
+	return items, rows.Err()
 }
 
 func panelExists(items []panelRow, id int64) bool {
@@ -1115,6 +9956,23 @@ func (s *server) resolvePanelID(ctx context.Context, requested int64) (int64, er
 	return fallback, nil
 }
 
+// parseFormBool reads a checkbox that is paired with a hidden fallback
+// input of the same name, so the last submitted value wins. It returns nil
+// when the field was not submitted at all.
+func parseFormBool(values []string) *bool {
+	if len(values) == 0 {
+		return nil
+	}
+	switch strings.ToLower(strings.TrimSpace(values[len(values)-1])) {
+	case "1", "on", "true", "yes":
+		v := true
+		return &v
+	default:
+		v := false
+		return &v
+	}
+}
+
 func parseInt64OrZero(value string) int64 {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -1138,28 +9996,460 @@ func parseIDList(raw string) []int64 {
 	return ids
 }
 
+const (
+	maxFetchRedirects = 5
+	maxFetchBytes     = 1 << 20
+)
+
+// fetchClient is used for every outbound request made on behalf of a
+// link. main replaces it when ALLOW_PRIVATE_FETCH is set.
+var fetchClient = newFetchClient(false)
+
+// errBlockedAddress is returned for fetches that would connect to an
+// address inside the server's own network.
+var errBlockedAddress = errors.New("private or loopback address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// blockedAddr reports addresses that lead into the server's own network
+// rather than the public internet.
+func blockedAddr(addr netip.Addr) bool {
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || sharedAddressSpace.Contains(addr)
+}
+
+// refuseBlockedAddr is a net.Dialer Control function. It runs on the
+// resolved address of every connection, redirects included, so a host
+// name pointing at a private address is refused too.
+func refuseBlockedAddr(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if addr := addrPort.Addr().Unmap(); blockedAddr(addr) {
+		return fmt.Errorf("%w %s", errBlockedAddress, addr)
+	}
+	return nil
+}
+
+// newFetchClient returns a client for link fetches. Unless allowPrivate
+// is set it refuses private, loopback and link-local addresses, and
+// ignores proxy settings, since a proxy would hide the target's address.
+func newFetchClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer.Control = refuseBlockedAddr
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+type openGraph struct {
+	Title       string
+	Description string
+	Image       string
+	// PageTitle is the document's <title>, kept apart from the og:title
+	// so enrichment only ever stores OpenGraph data.
+	PageTitle string
+}
+
+// fetchOpenGraph downloads at most maxFetchBytes of the page and reads the
+// og:title, og:description and og:image meta tags from it. Pages without
+// those tags yield an empty result rather than an error.
+func fetchOpenGraph(ctx context.Context, target string) (openGraph, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return openGraph{}, err
+	}
+	req.Header.Set("Accept", "text/html")
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return openGraph{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return openGraph{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return parseOpenGraph(io.LimitReader(resp.Body, maxFetchBytes)), nil
+}
+
+func parseOpenGraph(body io.Reader) openGraph {
+	var og openGraph
+	tokens := html.NewTokenizer(body)
+	for {
+		switch tokens.Next() {
+		case html.ErrorToken:
+			return og
+		case html.EndTagToken:
+			if name, _ := tokens.TagName(); string(name) == "head" {
+				return og
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokens.TagName()
+			if string(name) == "body" {
+				return og
+			}
+			if string(name) == "title" {
+				if tokens.Next() == html.TextToken {
+					og.PageTitle = strings.TrimSpace(string(tokens.Text()))
+				}
+				continue
+			}
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+			var property, content string
+			for {
+				key, val, more := tokens.TagAttr()
+				switch string(key) {
+				case "property", "name":
+					property = strings.ToLower(string(val))
+				case "content":
+					content = strings.TrimSpace(string(val))
+				}
+				if !more {
+					break
+				}
+			}
+			switch property {
+			case "og:title":
+				og.Title = content
+			case "og:description":
+				og.Description = content
+			case "og:image":
+				og.Image = content
+			}
+		}
+	}
+}
+
+// renderMarkdown converts a link description to sanitized HTML.
+func renderMarkdown(source string) template.HTML {
+	if strings.TrimSpace(source) == "" {
+		return ""
+	}
+	rendered := markdown.ToHTML([]byte(source), nil, nil)
+	return template.HTML(markdownPolicy.SanitizeBytes(rendered))
+}
+
+func unixOrZero(seconds int64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
 func isLikelyURL(url string) bool {
 	url = strings.ToLower(strings.TrimSpace(url))
 	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
 }
 
-func derivedLogoURL(rawURL string) string {
-	rawURL = strings.TrimSpace(rawURL)
-	if rawURL == "" {
+// monogramDataURI draws the first letter of name on a background whose hue
+// comes from the link's host, so every link on the same site shares a
+// color. It is a data URI and needs no network access.
+func monogramDataURI(name string, rawURL string) template.URL {
+	letter := "?"
+	if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name)); r != utf8.RuneError {
+		letter = strings.ToUpper(string(r))
+	}
+	host := rawURL
+	if parsed, err := url.Parse(rawURL); err == nil && parsed.Host != "" {
+		host = strings.ToLower(parsed.Hostname())
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	hue := h.Sum32() % 360
+
+	svg := fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="64" height="64" viewBox="0 0 64 64">`+
+			`<rect width="64" height="64" rx="12" fill="hsl(%d,55%%,45%%)"/>`+
+			`<text x="32" y="43" font-family="sans-serif" font-size="32" font-weight="600" text-anchor="middle" fill="#fff">%s</text>`+
+			`</svg>`,
+		hue, template.HTMLEscapeString(letter),
+	)
+	return template.URL("data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg)))
+}
+
+// defaultIconProvider is the ICON_PROVIDER used when none is set, and
+// selfIconProvider is what ICON_PROVIDER=self stands for.
+const (
+	defaultIconProvider = "https://www.google.com/s2/favicons?domain={host}&sz=64"
+	selfIconProvider    = "{scheme}://{host}/favicon.ico"
+)
+
+// settingIconProvider records the provider the stored logo URLs were
+// derived with, so a new ICON_PROVIDER re-derives them at startup.
+const settingIconProvider = "icon_provider"
+
+// parseIconProvider checks an ICON_PROVIDER value: "self" or an http(s)
+// URL template containing {host} and optionally {scheme}.
+func parseIconProvider(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	switch raw {
+	case "":
+		return defaultIconProvider, nil
+	case "self":
+		return selfIconProvider, nil
+	}
+	if !strings.Contains(raw, "{host}") {
+		return "", fmt.Errorf("ICON_PROVIDER must be self or a URL template containing {host}, got %q", raw)
+	}
+	parsed, err := url.Parse(derivedLogoURL(raw, "https://example.com"))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("ICON_PROVIDER must be an http or https URL template, got %q", raw)
+	}
+	return raw, nil
+}
+
+// derivedLogoURL fills the icon provider template with the link's host
+// and scheme; links without a host get no logo.
+func derivedLogoURL(provider string, rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
 		return ""
 	}
-	trimmed := strings.TrimPrefix(strings.TrimPrefix(rawURL, "https://"), "http://")
-	host := strings.Split(trimmed, "/")[0]
-	if host == "" {
-		return ""
+	scheme := parsed.Scheme
+	if scheme != "http" {
+		scheme = "https"
+	}
+	return strings.NewReplacer("{host}", parsed.Host, "{scheme}", scheme).Replace(provider)
+}
+
+func (s *server) derivedLogoURL(rawURL string) string {
+	provider := s.iconProvider
+	if provider == "" {
+		provider = defaultIconProvider
+	}
+	return derivedLogoURL(provider, rawURL)
+}
+
+// refreshDerivedLogos rewrites the logo URL of every link without a
+// custom logo when provider differs from the one they were derived with.
+func refreshDerivedLogos(db *sql.DB, cipher *fieldCipher, provider string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, settingIconProvider).Scan(&previous)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if previous == provider {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, url FROM links WHERE custom_logo_url = ''`)
+	if err != nil {
+		return err
+	}
+	logos := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var rawURL string
+		if err := rows.Scan(&id, &rawURL); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := cipher.openAll(&rawURL); err != nil {
+			rows.Close()
+			return err
+		}
+		logos[id] = derivedLogoURL(provider, rawURL)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, logo := range logos {
+		if _, err := tx.ExecContext(ctx, `UPDATE links SET logo_url = ? WHERE id = ?`, logo, id); err != nil {
+			return err
+		}
 	}
-	return "https://www.google.com/s2/favicons?domain=" + host + "&sz=64"
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO settings(key, value) VALUES(?, ?)`, settingIconProvider, provider); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func loggingMiddleware(next http.Handler) http.Handler {
+func loggingMiddleware(next http.Handler, proxies trustedProxies) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+		log.Printf("%s %s %s %s", proxies.clientIP(r), r.Method, r.URL.Path, time.Since(start))
+	})
+}
+
+// recoverMiddleware turns a panicking handler into a logged stack trace
+// and a plain 500 instead of a reset connection. It wraps everything else
+// so a panic in another middleware is caught too.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, debug.Stack())
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsOrigins is a set of normalised "scheme://host[:port]" origins.
+type corsOrigins map[string]bool
+
+// parseCORSOrigins reads a comma-separated list of origins. Wildcards are
+// rejected on purpose: every origin must be listed explicitly.
+func parseCORSOrigins(raw string) (corsOrigins, error) {
+	origins := corsOrigins{}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parsed, err := url.Parse(item)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			strings.Contains(parsed.Host, "*") || parsed.User != nil || strings.TrimSuffix(parsed.Path, "/") != "" ||
+			parsed.RawQuery != "" || parsed.Fragment != "" {
+			return nil, fmt.Errorf("CORS_ORIGINS: invalid origin %q, want scheme://host[:port]", item)
+		}
+		origins[strings.ToLower(parsed.Scheme+"://"+parsed.Host)] = true
+	}
+	return origins, nil
+}
+
+// corsMiddleware adds CORS headers to /api/ responses for allowed origins
+// and answers their preflight requests itself, since the mux only routes
+// GET and POST. Other origins get no CORS headers, so browsers block them.
+func corsMiddleware(next http.Handler, origins corsOrigins) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && origins[strings.ToLower(origin)]
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Disposition")
+		}
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, If-None-Match")
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		w.WriteHeader(http.StatusNoContent)
 	})
 }
+
+type trustedProxies []netip.Prefix
+
+// parseTrustedProxies reads a comma-separated list of proxy IPs or CIDRs.
+func parseTrustedProxies(raw string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXY: invalid CIDR %q", item)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXY: invalid IP %q", item)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+func (p trustedProxies) trusts(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that made the request.
+// Forwarding headers are only honoured when the direct peer is a trusted
+// proxy; X-Forwarded-For is then walked from the nearest hop backwards
+// and the first address that is not itself a trusted proxy wins.
+func (p trustedProxies) clientIP(r *http.Request) string {
+	peer := remoteAddr(r.RemoteAddr)
+	if !peer.IsValid() {
+		return r.RemoteAddr
+	}
+	if !p.trusts(peer) {
+		return peer.String()
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr := remoteAddr(strings.TrimSpace(hops[i]))
+		if !addr.IsValid() {
+			break
+		}
+		if !p.trusts(addr) {
+			return addr.String()
+		}
+	}
+	if addr := remoteAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); addr.IsValid() {
+		return addr.String()
+	}
+	return peer.String()
+}
+
+// remoteAddr parses "ip", "ip:port", "[ipv6]" or "[ipv6]:port".
+func remoteAddr(value string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
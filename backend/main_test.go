@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"html/template"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"modernc.org/sqlite"
+)
+
+// registerCollation registers nameCollation once per test binary, since
+// the sqlite driver keeps collations globally.
+var registerCollation = sync.OnceValue(func() error {
+	return sqlite.RegisterCollationUtf8(nameCollation, newNameComparer(defaultLocale))
+})
+
+// newTestServer returns a server over a fresh database in a temporary
+// directory, with the schema and seed data main would create.
+func newTestServer(tb testing.TB) *server {
+	tb.Helper()
+	if err := registerCollation(); err != nil {
+		tb.Fatalf("register collation: %v", err)
+	}
+	db, err := sql.Open("sqlite", filepath.Join(tb.TempDir(), "dashboard.sqlite"))
+	if err != nil {
+		tb.Fatalf("open sqlite: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
+		tb.Fatalf("enable foreign keys: %v", err)
+	}
+	if err := ensureSchema(db); err != nil {
+		tb.Fatalf("ensure schema: %v", err)
+	}
+	tpl, err := template.New("").Funcs(templateFuncs).ParseGlob("templates/*.html")
+	if err != nil {
+		tb.Fatalf("parse templates: %v", err)
+	}
+	return &server{db: db, templates: tpl, compareNames: newNameComparer(defaultLocale)}
+}
+
+// mustExec runs a setup statement and fails the test if it errors.
+func mustExec(tb testing.TB, s *server, query string, args ...any) sql.Result {
+	tb.Helper()
+	result, err := s.db.ExecContext(context.Background(), query, args...)
+	if err != nil {
+		tb.Fatalf("%s: %v", query, err)
+	}
+	return result
+}
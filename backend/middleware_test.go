@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the standard logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	logs := captureLog(t)
+	var nilMap map[string]*int
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = *nilMap["missing"]
+	})
+	handler := recoverMiddleware(loggingMiddleware(panicking, nil))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/boom", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "internal server error" {
+		t.Errorf("body = %q, want a plain error without details", body)
+	}
+	if !strings.Contains(logs.String(), "panic serving GET /boom") || !strings.Contains(logs.String(), "goroutine") {
+		t.Errorf("log does not hold the panic and its stack:\n%s", logs.String())
+	}
+}
+
+func TestRecoverMiddlewarePassesThrough(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want the handler's own 418", rec.Code)
+	}
+}
+
+// http.ErrAbortHandler is how handlers ask net/http to drop the
+// connection quietly, so it must not be turned into a 500.
+func TestRecoverMiddlewareRepanicsAbort(t *testing.T) {
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		recovered := recover()
+		if err, _ := recovered.(error); !errors.Is(err, http.ErrAbortHandler) {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", recovered)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	t.Fatal("ErrAbortHandler was swallowed")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefersJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html", false},
+		{"application/json", true},
+		{"application/json, text/html", false},
+		{"text/html;q=0.5, application/json", true},
+		{"application/json;q=0.9, */*;q=0.1", true},
+		{"text/*, application/json;q=0.8", false},
+		{"application/json;q=0", false},
+		{"text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/partials/dashboard", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := prefersJSON(r); got != tt.want {
+			t.Errorf("prefersJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func getDashboard(s *server, accept string, etag string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/partials/dashboard", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	rec := httptest.NewRecorder()
+	s.handleDashboard(rec, r)
+	return rec
+}
+
+func TestHandleDashboardNegotiation(t *testing.T) {
+	s := newTestServer(t)
+	mustExec(t, s, `INSERT INTO links(category_id, name, url, position) VALUES(1, 'Negotiated', 'https://example.com', 0)`)
+
+	html := getDashboard(s, "", "")
+	if html.Code != http.StatusOK {
+		t.Fatalf("html status = %d", html.Code)
+	}
+	if ct := html.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("default Content-Type = %q, want text/html", ct)
+	}
+	if !strings.Contains(html.Body.String(), "Negotiated") {
+		t.Error("html partial does not list the link")
+	}
+
+	js := getDashboard(s, "application/json", "")
+	if js.Code != http.StatusOK {
+		t.Fatalf("json status = %d", js.Code)
+	}
+	if ct := js.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("json Content-Type = %q", ct)
+	}
+	var data dashboardData
+	if err := json.Unmarshal(js.Body.Bytes(), &data); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if got := countLinks(data); got != 1 {
+		t.Errorf("json links = %d, want 1", got)
+	}
+
+	for _, rec := range []*httptest.ResponseRecorder{html, js} {
+		if vary := rec.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept") {
+			t.Errorf("Vary = %q, want Accept", vary)
+		}
+	}
+	htmlTag, jsonTag := html.Header().Get("ETag"), js.Header().Get("ETag")
+	if htmlTag == jsonTag {
+		t.Fatalf("html and json share the ETag %s", htmlTag)
+	}
+	if rec := getDashboard(s, "application/json", jsonTag); rec.Code != http.StatusNotModified {
+		t.Errorf("json with its own ETag: status %d, want 304", rec.Code)
+	}
+	if rec := getDashboard(s, "application/json", htmlTag); rec.Code != http.StatusOK {
+		t.Errorf("json with the html ETag: status %d, want 200", rec.Code)
+	}
+}
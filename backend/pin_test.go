@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinLockBlocksPerClient(t *testing.T) {
+	l := newPinLock("4711", time.Minute, nil)
+	for range pinMaxFailures {
+		if ok, err := l.check("203.0.113.9", "0000"); ok || err != nil {
+			t.Fatalf("wrong PIN: ok %v, err %v", ok, err)
+		}
+	}
+	if _, err := l.check("203.0.113.9", "4711"); !errors.Is(err, errPinBlocked) {
+		t.Errorf("blocked client with the right PIN: err = %v, want errPinBlocked", err)
+	}
+	if ok, err := l.check("198.51.100.7", "4711"); !ok || err != nil {
+		t.Errorf("another client while the first is blocked: ok %v, err %v", ok, err)
+	}
+}
+
+func TestPinLockSuccessResetsFailures(t *testing.T) {
+	l := newPinLock("4711", time.Minute, nil)
+	for range pinMaxFailures - 1 {
+		l.check("203.0.113.9", "0000")
+	}
+	if ok, _ := l.check("203.0.113.9", "4711"); !ok {
+		t.Fatal("right PIN refused")
+	}
+	if ok, err := l.check("203.0.113.9", "0000"); ok || err != nil {
+		t.Fatalf("wrong PIN after a success: ok %v, err %v", ok, err)
+	}
+	if ok, err := l.check("203.0.113.9", "4711"); !ok || err != nil {
+		t.Errorf("one failure after a success blocked the client: ok %v, err %v", ok, err)
+	}
+}
+
+func TestPinMiddlewareIgnoresQueryPin(t *testing.T) {
+	l := newPinLock("4711", time.Minute, nil)
+	handler := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/actions/links/create?pin=4711", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("PIN in the query: status %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/actions/links/create", nil)
+	req.Header.Set("X-Pin", "4711")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("PIN in X-Pin: status %d, want the handler's 204", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/partials/dashboard", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("read without a PIN: status %d, want it let through", rec.Code)
+	}
+}
+
+func TestUnlockReadsPinFromBodyOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.pin = newPinLock("4711", time.Minute, nil)
+	unlock := func(target string, body url.Values) int {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		s.handleUnlock(rec, req)
+		return rec.Code
+	}
+	if code := unlock("/actions/unlock?pin=4711", url.Values{}); code != http.StatusUnauthorized {
+		t.Errorf("PIN in the query: status %d, want 401", code)
+	}
+	if code := unlock("/actions/unlock", url.Values{"pin": {"4711"}}); code != http.StatusOK {
+		t.Errorf("PIN in the body: status %d, want 200", code)
+	}
+}
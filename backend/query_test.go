@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSQLQueryBuild(t *testing.T) {
+	const base = `SELECT l.id FROM links l JOIN categories c ON c.id = l.category_id`
+	tests := []struct {
+		name     string
+		query    *sqlQuery
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "base only",
+			query:   newSQLQuery(base),
+			wantSQL: base,
+		},
+		{
+			name:     "base arguments come first",
+			query:    newSQLQuery(`SELECT l.id, l.last_opened_at < ? FROM links l`, 5).where(`l.category_id = ?`, 2),
+			wantSQL:  `SELECT l.id, l.last_opened_at < ? FROM links l WHERE (l.category_id = ?)`,
+			wantArgs: []any{5, 2},
+		},
+		{
+			name: "where, order, limit and offset",
+			query: newSQLQuery(base).
+				where(`c.panel_id = ?`, int64(1)).
+				where(`l.name LIKE ? OR l.url LIKE ?`, "a", "b").
+				orderBy(`l.id ASC`).
+				page(10, 20),
+			wantSQL:  base + ` WHERE (c.panel_id = ?) AND (l.name LIKE ? OR l.url LIKE ?) ORDER BY l.id ASC LIMIT ? OFFSET ?`,
+			wantArgs: []any{int64(1), "a", "b", 10, 20},
+		},
+		{
+			name:     "limit without offset",
+			query:    newSQLQuery(base).orderBy(`l.id ASC`).page(5, 0),
+			wantSQL:  base + ` ORDER BY l.id ASC LIMIT ?`,
+			wantArgs: []any{5},
+		},
+		{
+			name:     "offset without limit",
+			query:    newSQLQuery(base).where(`l.category_id = ?`, int64(3)).page(0, 20),
+			wantSQL:  base + ` WHERE (l.category_id = ?) LIMIT ? OFFSET ?`,
+			wantArgs: []any{int64(3), -1, 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs := tt.query.build()
+			if gotSQL != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", gotSQL, tt.wantSQL)
+			}
+			if len(gotArgs) == 0 && len(tt.wantArgs) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSQLQueryBuildDoesNotGrowArgs(t *testing.T) {
+	q := newSQLQuery(`SELECT 1`).where(`x = ?`, 1).page(2, 3)
+	q.build()
+	if _, args := q.build(); len(args) != 3 {
+		t.Fatalf("second build args = %v, want 3 arguments", args)
+	}
+}
+
+func TestLinkFilterApply(t *testing.T) {
+	filter := linkFilter{
+		PanelID:      1,
+		CategoryID:   2,
+		Search:       `50%_off`,
+		OpenedBefore: 100,
+		ExpiresBy:    200,
+		Order:        flatLinkOrder,
+		Limit:        10,
+		Offset:       30,
+	}
+	gotSQL, gotArgs := filter.apply(newSQLQuery(`SELECT l.id FROM links l`)).build()
+	wantSQL := `SELECT l.id FROM links l WHERE (c.panel_id = ?) AND (l.category_id = ?)` +
+		` AND (l.name LIKE '%' || ? || '%' ESCAPE '\' OR l.url LIKE '%' || ? || '%' ESCAPE '\')` +
+		` AND (l.last_opened_at < ?) AND (l.expires_at > 0 AND l.expires_at <= ?)` +
+		` ORDER BY ` + flatLinkOrder + ` LIMIT ? OFFSET ?`
+	if gotSQL != wantSQL {
+		t.Errorf("sql = %q\nwant  %q", gotSQL, wantSQL)
+	}
+	wantArgs := []any{int64(1), int64(2), `50\%\_off`, `50\%\_off`, int64(100), int64(200), 10, 30}
+	if !reflect.DeepEqual(gotArgs, wantArgs) {
+		t.Errorf("args = %#v, want %#v", gotArgs, wantArgs)
+	}
+
+	filter.NameOnly = true
+	filter.PanelID, filter.CategoryID, filter.OpenedBefore, filter.ExpiresBy, filter.Limit, filter.Offset = 0, 0, 0, 0, 0, 0
+	filter.Order = ""
+	gotSQL, gotArgs = filter.apply(newSQLQuery(`SELECT l.id FROM links l`)).build()
+	if want := `SELECT l.id FROM links l WHERE (l.name LIKE '%' || ? || '%' ESCAPE '\')`; gotSQL != want {
+		t.Errorf("name-only sql = %q, want %q", gotSQL, want)
+	}
+	if len(gotArgs) != 1 {
+		t.Errorf("name-only args = %#v, want one pattern", gotArgs)
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErr    bool
+	}{
+		{"", 0, 0, false},
+		{"limit=10", 10, 0, false},
+		{"limit=10&offset=20", 10, 20, false},
+		{"offset=20", 0, 20, false},
+		{"limit=9999", maxListLimit, 0, false},
+		{"limit=0", 0, 0, true},
+		{"limit=abc", 0, 0, true},
+		{"offset=-1", 0, 0, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/links?"+tt.query, nil)
+		limit, offset, err := parsePage(r, maxListLimit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePage(%q) err = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if limit != tt.wantLimit || offset != tt.wantOffset {
+			t.Errorf("parsePage(%q) = %d, %d, want %d, %d", tt.query, limit, offset, tt.wantLimit, tt.wantOffset)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMatchStrength(t *testing.T) {
+	tests := []struct {
+		text, query string
+		want        int
+	}{
+		{"github", "git", scorePrefix},
+		{"my github", "git", scoreWordStart},
+		{"docs/git-guide", "git", scoreWordStart},
+		{"legit", "git", scoreSubstring},
+		{"gitlab legit", "lab", scoreSubstring},
+		{"news", "git", 0},
+		{"anything", "", 0},
+	}
+	for _, tt := range tests {
+		if got := matchStrength(tt.text, tt.query); got != tt.want {
+			t.Errorf("matchStrength(%q, %q) = %d, want %d", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchScoreOrdering(t *testing.T) {
+	type fixture struct {
+		name, url string
+		clicks    int
+	}
+	fixtures := []fixture{
+		{"Legit Reviews", "https://reviews.example", 500},
+		{"Forgejo", "https://git.example.org", 0},
+		{"My Git Notes", "https://notes.example", 0},
+		{"GitHub", "https://github.com", 0},
+		{"GitLab", "https://gitlab.com", 40},
+	}
+	var results []searchResult
+	for i, f := range fixtures {
+		results = append(results, searchResult{
+			ID:         int64(i + 1),
+			Link:       quickOpenItem{Name: f.name, URL: f.url},
+			ClickCount: f.clicks,
+			Score:      searchScore("git", f.name, f.url, f.clicks),
+		})
+	}
+	rankSearchResults(results, newNameComparer(defaultLocale))
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Link.Name)
+	}
+	// Name prefixes first, with clicks breaking the tie, then a word start
+	// in the name, then a URL prefix, and a plain substring last no matter
+	// how popular it is.
+	want := []string{"GitLab", "GitHub", "My Git Notes", "Forgejo", "Legit Reviews"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ranking = %q, want %q", got, want)
+	}
+}
+
+func TestSearchScoreClickBonusIsCapped(t *testing.T) {
+	popular := searchScore("git", "Legit", "https://legit.example", 1_000_000)
+	wordStart := searchScore("git", "My Git", "https://my.example", 0)
+	if popular >= wordStart {
+		t.Errorf("substring match with many clicks scored %v, not below a word-start match at %v", popular, wordStart)
+	}
+}
+
+func TestSearchLinksRanksCandidates(t *testing.T) {
+	s := newTestServer(t)
+	for i, name := range []string{"Legit Reviews", "GitHub", "Forgejo", "My Git Notes"} {
+		url := "https://site" + string(rune('a'+i)) + ".example"
+		if name == "Forgejo" {
+			url = "https://git.example.org"
+		}
+		mustExec(t, s, `INSERT INTO links(category_id, name, url, position) VALUES(1, ?, ?, ?)`, name, url, i)
+	}
+	mustExec(t, s, `INSERT INTO links(category_id, name, url, position) VALUES(1, 'Weather', 'https://weather.example', 9)`)
+
+	results, err := s.searchLinks(context.Background(), "git", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Link.Name)
+	}
+	want := []string{"GitHub", "My Git Notes", "Forgejo", "Legit Reviews"}
+	if !slices.Equal(got, want) {
+		t.Errorf("searchLinks = %q, want %q", got, want)
+	}
+
+	limited, err := s.searchLinks(context.Background(), "git", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited) != 2 || limited[0].Link.Name != "GitHub" {
+		t.Errorf("limited results = %+v, want the top 2", limited)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestDisplayHost(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://www.example.com/a?b=c", "example.com"},
+		{"https://Docs.Example.COM:8443/path", "docs.example.com"},
+		{"http://[::1]:8080/", "::1"},
+		{"  https://example.org  ", "example.org"},
+		{"not a url", "not a url"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := displayHost(tt.in); got != tt.want {
+			t.Errorf("displayHost(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		max        int
+		text, want string
+	}{
+		{10, "short", "short"},
+		{5, "exact", "exact"},
+		{5, "toolong", "tool…"},
+		{3, "äöüß", "äö…"},
+		{1, "abc", "…"},
+		{0, "unlimited", "unlimited"},
+		{-1, "unlimited", "unlimited"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.max, tt.text); got != tt.want {
+			t.Errorf("truncateText(%d, %q) = %q, want %q", tt.max, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateFuncsInPipeline(t *testing.T) {
+	tpl := template.Must(template.New("t").Funcs(templateFuncs).Parse(`{{host .}}|{{. | truncate 12}}`))
+	var out strings.Builder
+	if err := tpl.Execute(&out, "https://www.example.com/long/path"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "example.com|https://www…"; out.String() != want {
+		t.Errorf("rendered %q, want %q", out.String(), want)
+	}
+}
+
+// TestCheckTemplates renders every template with its samples, the same
+// check main runs before serving.
+func TestCheckTemplates(t *testing.T) {
+	s := newTestServer(t)
+	if err := checkTemplates(s.templates); err != nil {
+		t.Fatal(err)
+	}
+}
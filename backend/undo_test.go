@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestUndoConflictKeepsEntry restores a category that still exists, so the
+// insert breaks the primary key. The entry has to survive for a later try
+// and the driver's message must not reach the client.
+func TestUndoConflictKeepsEntry(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories, err := snapshotRowsTx(ctx, tx, "categories", "id = ?", 1)
+	tx.Rollback()
+	if err != nil || len(categories) != 1 {
+		t.Fatalf("snapshot category 1: %v (%d rows)", err, len(categories))
+	}
+	s.undo.push(undoEntry{label: "category", categories: categories})
+
+	rec := httptest.NewRecorder()
+	s.handleUndo(rec, httptest.NewRequest("POST", "/actions/undo", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want 409", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "constraint") {
+		t.Errorf("response leaks the sqlite error: %s", body)
+	}
+	if _, ok := s.undo.pop(time.Now()); !ok {
+		t.Error("failed undo dropped the entry")
+	}
+}
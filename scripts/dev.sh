@@ -8,10 +8,8 @@ if [ ! -f "$BACKEND_DIR/.env" ]; then
   cp "$BACKEND_DIR/.env.example" "$BACKEND_DIR/.env"
 fi
 
-set -a
-source "$BACKEND_DIR/.env"
-set +a
-
+# The backend reads .env itself, without overriding variables already
+# set in this shell.
 cd "$BACKEND_DIR"
 go run . &
 BACKEND_PID=$!